@@ -0,0 +1,118 @@
+package gex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// AccessLogConfig configures AccessLogMiddleware
+type AccessLogConfig struct {
+	// Logger receives the access log lines. Nil uses slog.Default().
+	Logger *slog.Logger
+
+	// SkipPaths lists exact URL paths that are never logged, e.g. health checks
+	SkipPaths []string
+
+	// Skip, when set, drops any request for which it returns true
+	Skip func(r *http.Request) bool
+
+	// SampleRate is the fraction of successful requests to log, between 0 and 1.
+	// Zero logs every request. Responses with status 500 and above are always logged.
+	SampleRate float64
+}
+
+// AccessLogMiddleware logs one structured line per request with the method, route pattern,
+// status, bytes written, latency, remote IP and request ID, plus any attributes
+// added with AddLogAttrs, such as a session_key_hash from SessionKeyHash.
+// Register it first so it wraps the other registered middleware. The app's own
+// client certificate, proxy header, metrics, CORS and body size middleware
+// still run outside it, so CORS preflights answered by the policy are not logged.
+func AccessLogMiddleware(cfg AccessLogConfig) Middleware {
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] || (cfg.Skip != nil && cfg.Skip(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			st, r := withRequestState(r)
+			rw := newResponseWriter(w)
+
+			next.ServeHTTP(rw, r)
+
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < 500 && cfg.SampleRate > 0 && cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+				return
+			}
+
+			logger := cfg.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("pattern", st.routePattern()),
+				slog.Int("status", status),
+				slog.Int64("bytes", rw.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", ClientIP(r)),
+			}
+			if id := requestIDOf(r, rw); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			attrs = append(attrs, st.logAttrs()...)
+
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
+
+// SessionKeyHash returns a short, non-reversible fingerprint of a session key,
+// safe to write to logs in place of the key itself
+func SessionKeyHash(sessionKey string) string {
+	if sessionKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sessionKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// requestIDOf finds the request ID whether the ID middleware runs inside or
+// outside the caller: from the context, else the echoed response header
+func requestIDOf(r *http.Request, w http.ResponseWriter) string {
+	if id := RequestID(r.Context()); id != "" {
+		return id
+	}
+	return w.Header().Get(RequestIDHeader)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
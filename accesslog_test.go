@@ -0,0 +1,100 @@
+package gex
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "not here", http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+	cfg := AccessLogConfig{
+		SkipPaths: []string{"/healthz"},
+		Skip:      func(r *http.Request) bool { return r.Header.Get("X-Skip") != "" },
+	}
+
+	tests := []struct {
+		name       string
+		cfg        AccessLogConfig
+		path       string
+		header     string
+		wantLogged bool
+		wantLevel  string
+		wantStatus int
+		wantBytes  int
+	}{
+		{"success", cfg, "/hello", "", true, "INFO", http.StatusOK, 5},
+		{"client error", cfg, "/missing", "", true, "WARN", http.StatusNotFound, len("not here\n")},
+		{"server error", cfg, "/broken", "", true, "ERROR", http.StatusInternalServerError, 0},
+		{"skipped path", cfg, "/healthz", "", false, "", 0, 0},
+		{"skip func", cfg, "/hello", "yes", false, "", 0, 0},
+		{"sampled out", AccessLogConfig{SampleRate: 1e-12}, "/hello", "", false, "", 0, 0},
+		{"server errors are never sampled out", AccessLogConfig{SampleRate: 1e-12}, "/broken", "", true, "ERROR", http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			tt.cfg.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+			h := AccessLogMiddleware(tt.cfg)(handler)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Skip", tt.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.wantLogged {
+				if logs.Len() != 0 {
+					t.Errorf("logged %s, want nothing", logs.String())
+				}
+				return
+			}
+			var entry struct {
+				Level  string `json:"level"`
+				Msg    string `json:"msg"`
+				Method string `json:"method"`
+				Path   string `json:"path"`
+				Status int    `json:"status"`
+				Bytes  int    `json:"bytes"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("log line %q: %v", logs.String(), err)
+			}
+			if entry.Level != tt.wantLevel || entry.Status != tt.wantStatus || entry.Bytes != tt.wantBytes {
+				t.Errorf("level %s, status %d, bytes %d; want %s, %d, %d", entry.Level, entry.Status, entry.Bytes, tt.wantLevel, tt.wantStatus, tt.wantBytes)
+			}
+			if entry.Method != http.MethodGet || entry.Path != tt.path || entry.Msg != "http request" {
+				t.Errorf("entry = %+v", entry)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddlewareInApp(t *testing.T) {
+	var logs bytes.Buffer
+	app := NewApp(HostConfig{}, http.NotFound)
+	app.RegisterMiddleware(AccessLogMiddleware(AccessLogConfig{Logger: slog.New(slog.NewJSONHandler(&logs, nil))}))
+	app.RegisterMiddleware(RequestIDMiddleware())
+	app.AddRoute("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+
+	for _, want := range []string{`"pattern":"GET /users/{id}"`, `"request_id":"`, `"status":200`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log line %s missing %s", logs.String(), want)
+		}
+	}
+}
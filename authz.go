@@ -0,0 +1,149 @@
+package gex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// Session values read by the authorization guards. The app's login flow
+// is expected to Put them once a user has authenticated.
+const (
+	SessionUserID   = "user_id"   // positive integer ID of the logged in user, as a string or integer
+	SessionRole     = "role"      // string or []string
+	SessionIsSecure = "is_secure" // bool, true once the session passed step-up auth, e.g. 2FA
+)
+
+// UserID returns the ID of the user logged in to the request's session, or "" if there is none.
+// IDs that are not positive integers, e.g. "0" or "-1", count as no user.
+func UserID(ctx context.Context) string {
+	result, ok := sessionprovider.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	raw, ok := result.Session.Get(SessionUserID)
+	if !ok || raw == nil {
+		return ""
+	}
+
+	id, err := strconv.ParseUint(fmt.Sprint(raw), 10, 64)
+	if err != nil || id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+// Roles returns the roles of the request's session and JWT claims
+func Roles(ctx context.Context) []string {
+	result, ok := sessionprovider.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var roles []string
+	if result.Claims != nil && result.Claims.Role != "" {
+		roles = append(roles, result.Claims.Role)
+	}
+	if raw, ok := result.Session.Get(SessionRole); ok {
+		switch v := raw.(type) {
+		case string:
+			roles = append(roles, v)
+		case []string:
+			roles = append(roles, v...)
+		}
+	}
+	return roles
+}
+
+// IsSecure reports whether the request's session, or its JWT claims, is marked secure
+func IsSecure(ctx context.Context) bool {
+	result, ok := sessionprovider.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if result.Claims != nil && result.Claims.IsSecure {
+		return true
+	}
+	secure, _ := result.Session.Get(SessionIsSecure)
+	isSecure, _ := secure.(bool)
+	return isSecure
+}
+
+// RequireAuthenticated rejects requests whose session has no logged in user with a JSON 401.
+// It must run inside SessionMiddleware, as must every guard below.
+func RequireAuthenticated() Middleware {
+	return guard("gex.RequireAuthenticated", func(r *http.Request) error {
+		if UserID(r.Context()) == "" {
+			return ErrUnauthorized
+		}
+		return nil
+	})
+}
+
+// RequireUser lets a request through only if allow accepts the logged in user,
+// e.g. to restrict /users/{id} to its owner. It answers 401 without a user and 403 if allow refuses.
+func RequireUser(allow func(r *http.Request, userID string) bool) Middleware {
+	return guard("gex.RequireUser", func(r *http.Request) error {
+		userID := UserID(r.Context())
+		if userID == "" {
+			return ErrUnauthorized
+		}
+		if !allow(r, userID) {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+// RequireSecure requires a logged in user whose session is marked secure.
+// It answers 401 without a user and 403 if the session is not secure.
+func RequireSecure() Middleware {
+	return guard("gex.RequireSecure", func(r *http.Request) error {
+		if UserID(r.Context()) == "" {
+			return ErrUnauthorized
+		}
+		if !IsSecure(r.Context()) {
+			return ErrForbidden.WithDetails(map[string]string{"reason": "secure_session_required"})
+		}
+		return nil
+	})
+}
+
+// RequireRole requires a logged in user holding at least one of roles.
+// It answers 401 without a user and 403 without a matching role.
+func RequireRole(roles ...string) Middleware {
+	return guard("gex.RequireRole", func(r *http.Request) error {
+		if UserID(r.Context()) == "" {
+			return ErrUnauthorized
+		}
+		for _, role := range Roles(r.Context()) {
+			if slices.Contains(roles, role) {
+				return nil
+			}
+		}
+		return ErrForbidden.WithDetails(map[string]string{"reason": "role_required"})
+	})
+}
+
+// guard builds a GuardMiddleware named name that writes check's error, if any,
+// instead of calling next
+func guard(name string, check func(r *http.Request) error) Middleware {
+	return GuardMiddleware(name, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := sessionprovider.FromContext(r.Context()); !ok {
+				WriteError(w, r, ErrUnauthorized)
+				return
+			}
+			if err := check(r); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserID(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  any
+		want string
+	}{
+		{"string", "42", "42"},
+		{"integer", 42, "42"},
+		{"int64", int64(7), "7"},
+		{"leading zeros", "007", "7"},
+		{"zero", "0", ""},
+		{"zero integer", 0, ""},
+		{"negative", "-1", ""},
+		{"negative integer", -1, ""},
+		{"not a number", "admin", ""},
+		{"empty", "", ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := sessionRequest(false, map[string]any{SessionUserID: tt.raw})
+			if got := UserID(r.Context()); got != tt.want {
+				t.Errorf("UserID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := UserID(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != "" {
+		t.Errorf("UserID without a session = %q, want empty", got)
+	}
+}
+
+func TestGuards(t *testing.T) {
+	ownerOnly := RequireUser(func(r *http.Request, userID string) bool { return userID == "42" })
+
+	noSession := func() *http.Request { return httptest.NewRequest(http.MethodGet, "/", nil) }
+	session := func(values map[string]any) func() *http.Request {
+		return func() *http.Request { return sessionRequest(false, values) }
+	}
+	user := map[string]any{SessionUserID: "42", SessionRole: "editor"}
+	admin := map[string]any{SessionUserID: "42", SessionRole: []string{"editor", "admin"}}
+	secure := map[string]any{SessionUserID: "42", SessionIsSecure: true}
+	other := map[string]any{SessionUserID: "7"}
+
+	tests := []struct {
+		name       string
+		guard      Middleware
+		r          func() *http.Request
+		wantStatus int
+	}{
+		{"authenticated: no session", RequireAuthenticated(), noSession, http.StatusUnauthorized},
+		{"authenticated: anonymous", RequireAuthenticated(), session(nil), http.StatusUnauthorized},
+		{"authenticated: negative id", RequireAuthenticated(), session(map[string]any{SessionUserID: "-1"}), http.StatusUnauthorized},
+		{"authenticated: pass", RequireAuthenticated(), session(user), http.StatusOK},
+		{"user: no session", ownerOnly, noSession, http.StatusUnauthorized},
+		{"user: anonymous", ownerOnly, session(nil), http.StatusUnauthorized},
+		{"user: someone else", ownerOnly, session(other), http.StatusForbidden},
+		{"user: pass", ownerOnly, session(user), http.StatusOK},
+		{"secure: no session", RequireSecure(), noSession, http.StatusUnauthorized},
+		{"secure: anonymous", RequireSecure(), session(map[string]any{SessionIsSecure: true}), http.StatusUnauthorized},
+		{"secure: missing flag", RequireSecure(), session(user), http.StatusForbidden},
+		{"secure: pass", RequireSecure(), session(secure), http.StatusOK},
+		{"role: no session", RequireRole("admin"), noSession, http.StatusUnauthorized},
+		{"role: anonymous", RequireRole("admin"), session(map[string]any{SessionRole: "admin"}), http.StatusUnauthorized},
+		{"role: wrong role", RequireRole("admin"), session(user), http.StatusForbidden},
+		{"role: pass", RequireRole("admin"), session(admin), http.StatusOK},
+		{"role: any of several", RequireRole("owner", "editor"), session(user), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tt.r())
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package gex
+
+import "net/http"
+
+// Chain is an ordered list of middleware.
+// The first middleware in the chain is the outermost one: it sees the request
+// first and the response last.
+type Chain []Middleware
+
+// NewChain creates a chain from the given middleware, outermost first
+func NewChain(middleware ...Middleware) Chain {
+	return append(Chain{}, middleware...)
+}
+
+// Append returns a new chain with middleware added after (inside) the existing ones.
+// The receiver is never modified, so a shared base chain can be appended to safely.
+func (c Chain) Append(middleware ...Middleware) Chain {
+	out := make(Chain, 0, len(c)+len(middleware))
+	out = append(out, c...)
+	return append(out, middleware...)
+}
+
+// Extend returns a new chain with all of other's middleware added after (inside) c
+func (c Chain) Extend(other Chain) Chain {
+	return c.Append(other...)
+}
+
+// Then wraps handler with the chain and returns the resulting handler.
+// Middleware may return any http.Handler.
+func (c Chain) Then(handler http.Handler) http.Handler {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	for i := len(c) - 1; i >= 0; i-- {
+		handler = c[i](handler)
+	}
+	return handler
+}
+
+// ThenFunc is Then for an http.HandlerFunc
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
@@ -0,0 +1,55 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	a, b, c := traceMiddleware("a"), traceMiddleware("b"), traceMiddleware("c")
+	base := NewChain(a)
+
+	tests := []struct {
+		name  string
+		chain Chain
+		want  string
+	}{
+		{"empty", NewChain(), ""},
+		{"outermost first", NewChain(a, b, c), "a,b,c"},
+		{"append", base.Append(b, c), "a,b,c"},
+		{"extend", base.Extend(NewChain(c, b)), "a,c,b"},
+		{"base untouched", base, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := strings.Join(rec.Header().Values("X-Trace"), ","); got != tt.want {
+				t.Errorf("order = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainAppendDoesNotAlias(t *testing.T) {
+	base := make(Chain, 1, 4)
+	base[0] = traceMiddleware("base")
+
+	x := base.Append(traceMiddleware("x"))
+	y := base.Append(traceMiddleware("y"))
+
+	rec := httptest.NewRecorder()
+	x.ThenFunc(func(w http.ResponseWriter, r *http.Request) {}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(rec.Header().Values("X-Trace"), ","); got != "base,x" {
+		t.Errorf("x = %q, want base,x (y is %d long)", got, len(y))
+	}
+}
+
+func TestChainThenNil(t *testing.T) {
+	if h := NewChain().Then(nil); h != http.DefaultServeMux {
+		t.Errorf("Then(nil) = %v, want http.DefaultServeMux", h)
+	}
+}
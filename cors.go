@@ -0,0 +1,164 @@
+package gex
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// CORSConfig is the cross-origin policy applied by App.SetupServerCORS
+type CORSConfig struct {
+	// AllowedOrigins lists the allowed origins. An entry may contain a single
+	// wildcard, e.g. "https://*.example.com", and "*" allows every origin.
+	AllowedOrigins []string
+
+	// AllowOriginFunc, when set, decides whether an origin is allowed and
+	// takes precedence over AllowedOrigins. Responses only vary on Origin,
+	// so the decision must not depend on other request headers.
+	AllowOriginFunc func(r *http.Request, origin string) bool
+
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+
+	// AllowCredentials lets browsers send cookies and Authorization headers.
+	// It cannot be combined with a "*" origin, which would let any site make
+	// credentialed requests: list the origins or set AllowOriginFunc.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response.
+	// Zero leaves it to the browser, a negative value disables caching.
+	MaxAge time.Duration
+
+	// Routes overrides the policy for requests whose path is under the given
+	// prefix, matched on whole segments: "/api" matches "/api" and "/api/keys"
+	// but not "/apix". The longest matching prefix wins and its config
+	// replaces this one entirely.
+	Routes map[string]CORSConfig
+}
+
+// DefaultCORSConfig returns the permissive policy gex has always used:
+// any origin, any header, no credentials.
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		ExposedHeaders:   []string{"*"},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: false,
+	}
+}
+
+// CORSMiddleware returns a middleware enforcing cfg, including its route overrides.
+// It panics if cfg or an override allows credentials from any origin.
+func CORSMiddleware(cfg CORSConfig) Middleware {
+	cfg.mustValidate("")
+	for prefix, route := range cfg.Routes {
+		route.mustValidate(prefix)
+	}
+	base := cfg.options()
+
+	// Longest prefix first so the most specific override wins
+	prefixes := make([]string, 0, len(cfg.Routes))
+	for prefix := range cfg.Routes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+
+	return func(next http.Handler) http.Handler {
+		h := &corsHandler{base: cors.New(base).Handler(next)}
+		for _, prefix := range prefixes {
+			h.routes = append(h.routes, corsRoute{
+				prefix:  prefix,
+				handler: cors.New(cfg.Routes[prefix].options()).Handler(next),
+			})
+		}
+		return h
+	}
+}
+
+func (c CORSConfig) options() cors.Options {
+	opts := cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+	}
+
+	if allow := c.AllowOriginFunc; allow != nil {
+		opts.AllowOriginVaryRequestFunc = func(r *http.Request, origin string) (bool, []string) {
+			return allow(r, origin), nil
+		}
+	}
+
+	switch {
+	case c.MaxAge > 0:
+		opts.MaxAge = int(c.MaxAge / time.Second)
+	case c.MaxAge < 0:
+		opts.MaxAge = -1
+	}
+
+	return opts
+}
+
+func (c CORSConfig) mustValidate(prefix string) {
+	if c.AllowCredentials && c.AllowOriginFunc == nil && c.allowsAnyOrigin() {
+		where := "CORS"
+		if prefix != "" {
+			where = fmt.Sprintf("CORS route %q", prefix)
+		}
+		panic(fmt.Sprintf(`gex: %s allows credentials from origin "*"; list the origins or set AllowOriginFunc`, where))
+	}
+}
+
+func (c CORSConfig) allowsAnyOrigin() bool {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type corsRoute struct {
+	prefix  string
+	handler http.Handler
+}
+
+type corsHandler struct {
+	base   http.Handler
+	routes []corsRoute
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, route := range h.routes {
+		if hasPathPrefix(r.URL.Path, route.prefix) {
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	h.base.ServeHTTP(w, r)
+}
+
+// hasPathPrefix reports whether path is prefix or lies under it, so that
+// "/api" matches "/api/keys" but not "/apix"
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -0,0 +1,80 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSMiddlewareRejectsCredentialedWildcard(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       CORSConfig
+		wantPanic bool
+	}{
+		{"wildcard without credentials", CORSConfig{AllowedOrigins: []string{"*"}}, false},
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true},
+		{"listed origins with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example"}, AllowCredentials: true}, false},
+		{
+			"origin func with credentials",
+			CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true, AllowOriginFunc: func(*http.Request, string) bool { return true }},
+			false,
+		},
+		{
+			"route override with credentials",
+			CORSConfig{Routes: map[string]CORSConfig{"/api/": {AllowedOrigins: []string{"*"}, AllowCredentials: true}}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if got := recover() != nil; got != tt.wantPanic {
+					t.Errorf("panicked = %v, want %v", got, tt.wantPanic)
+				}
+			}()
+			CORSMiddleware(tt.cfg)
+		})
+	}
+}
+
+func TestCORSMiddlewareOrigins(t *testing.T) {
+	h := CORSMiddleware(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example"},
+		AllowCredentials: true,
+		Routes: map[string]CORSConfig{
+			"/public/": {
+				AllowOriginFunc: func(r *http.Request, origin string) bool { return origin == "https://partner.example" },
+			},
+			"/api": {AllowedOrigins: []string{"https://api.example"}},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		path, origin string
+		wantOrigin   string
+		wantCreds    string
+	}{
+		{"/", "https://app.example", "https://app.example", "true"},
+		{"/", "https://evil.example", "", ""},
+		{"/public/x", "https://partner.example", "https://partner.example", ""},
+		{"/public/x", "https://app.example", "", ""},
+		{"/api", "https://api.example", "https://api.example", ""},
+		{"/api/keys", "https://api.example", "https://api.example", ""},
+		{"/apix", "https://api.example", "", ""},
+		{"/apix", "https://app.example", "https://app.example", "true"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s from %s: Allow-Origin = %q, want %q", tt.path, tt.origin, got, tt.wantOrigin)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+			t.Errorf("%s from %s: Allow-Credentials = %q, want %q", tt.path, tt.origin, got, tt.wantCreds)
+		}
+	}
+}
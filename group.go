@@ -0,0 +1,87 @@
+package gex
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of routes sharing a path prefix and a middleware stack.
+// Groups can be nested; each level adds its prefix and middleware on top of its parent's.
+type Group struct {
+	mux        *gexMux
+	prefix     string
+	middleware Chain
+}
+
+// Group creates a route group rooted at prefix.
+// The middleware is applied to every route registered through the group.
+func (a *App) Group(prefix string, middleware ...Middleware) *Group {
+	return &Group{
+		mux:        a.mux,
+		prefix:     cleanPrefix(prefix),
+		middleware: NewChain(middleware...),
+	}
+}
+
+// Group creates a nested group whose prefix and middleware stack on top of g.
+func (g *Group) Group(prefix string, middleware ...Middleware) *Group {
+	return &Group{
+		mux:        g.mux,
+		prefix:     g.prefix + cleanPrefix(prefix),
+		middleware: g.middleware.Append(middleware...),
+	}
+}
+
+// AddRoute registers a route under the group prefix.
+// The pattern may include a method and host, e.g. "POST /login".
+// Group middleware wraps the route middleware.
+func (g *Group) AddRoute(pattern string, handler http.HandlerFunc, middleware ...Middleware) *Route {
+	return g.mux.addRoute(joinPattern(g.prefix, pattern), handler, g.middleware.Append(middleware...))
+}
+
+// joinPattern inserts prefix in front of the path of a ServeMux pattern,
+// keeping any leading method and host intact.
+func joinPattern(prefix, pattern string) string {
+	method, host, path := splitPattern(pattern)
+
+	joined := host + joinPath(prefix, path)
+	if method != "" {
+		return method + " " + joined
+	}
+	return joined
+}
+
+// splitPattern splits a ServeMux pattern "[METHOD ][HOST]/[PATH]" into its parts
+func splitPattern(pattern string) (method, host, path string) {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 && !strings.Contains(pattern[:i], "/") {
+		method = pattern[:i]
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		host, path = pattern[:i], pattern[i:]
+	} else {
+		host = pattern
+	}
+	return method, host, path
+}
+
+// joinPath joins a group prefix and a route path
+func joinPath(prefix, path string) string {
+	if path == "" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	return prefix + path
+}
+
+// cleanPrefix normalizes a group prefix to "/a/b" form, or "" for the root
+func cleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
@@ -0,0 +1,86 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJoinPattern(t *testing.T) {
+	tests := []struct {
+		prefix, pattern, want string
+	}{
+		{"/api", "/users", "/api/users"},
+		{"/api", "GET /users/{id}", "GET /api/users/{id}"},
+		{"/api", "POST example.com/login", "POST example.com/api/login"},
+		{"/api", "example.com/", "example.com/api/"},
+		{"/api", "GET ", "GET /api"},
+		{"", "GET /users", "GET /users"},
+		{"", "", "/"},
+	}
+	for _, tt := range tests {
+		if got := joinPattern(tt.prefix, tt.pattern); got != tt.want {
+			t.Errorf("joinPattern(%q, %q) = %q, want %q", tt.prefix, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCleanPrefix(t *testing.T) {
+	tests := map[string]string{
+		"":       "",
+		"/":      "",
+		"api":    "/api",
+		"/api/":  "/api",
+		"//v1//": "/v1",
+	}
+	for prefix, want := range tests {
+		if got := cleanPrefix(prefix); got != want {
+			t.Errorf("cleanPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+// traceMiddleware appends name to the X-Trace response header
+func traceMiddleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := NewApp(HostConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) }
+
+	api := app.Group("/api/", traceMiddleware("api"))
+	api.AddRoute("GET /status", ok)
+	v1 := api.Group("v1", traceMiddleware("v1"))
+	v1.AddRoute("GET /users/{id}", ok, traceMiddleware("route"))
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantTrace  string
+	}{
+		{"/api/status", http.StatusOK, "api"},
+		{"/api/v1/users/7", http.StatusOK, "api,v1,route"},
+		{"/status", http.StatusNotFound, ""},
+		{"/v1/users/7", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		app.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.wantStatus)
+		}
+		if got := strings.Join(rec.Header().Values("X-Trace"), ","); got != tt.wantTrace {
+			t.Errorf("%s: middleware = %q, want %q", tt.target, got, tt.wantTrace)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package gex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// Validator is implemented by request types that check themselves
+// after binding and tag validation
+type Validator interface {
+	Validate() error
+}
+
+// Handle adapts a typed service func to an http.HandlerFunc.
+//
+// The request is bound into a new Req: the JSON body first, then query
+// parameters for fields tagged `query:"name"` and path values for fields
+// tagged `path:"name"`. The result is checked against its `validate` tags and,
+// if Req implements Validator, its Validate method. Binding and validation
+// failures answer 400 with per-field details.
+//
+// A non-nil response is written as JSON with status 200, a nil one as 204.
+// Errors are written with WriteError, so registered errors map to their status.
+//
+// Handle panics if the `validate` tags of Req are malformed or unknown.
+func Handle[Req, Resp any](fn func(ctx context.Context, req *Req) (*Resp, error)) http.HandlerFunc {
+	if t := reflect.TypeFor[Req](); t.Kind() == reflect.Struct {
+		if err := checkValidateTags(t); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+		if err := Bind(r, req); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// Bind decodes r into dst, a pointer to a struct, and validates it as described on Handle.
+// An error from Validator that is not an HTTPError becomes ErrBadRequest with
+// its message as details.
+func Bind(r *http.Request, dst any) error {
+	if err := bindBody(r, dst); err != nil {
+		return err
+	}
+	if err := bindValues(r, dst); err != nil {
+		return err
+	}
+	if err := Validate(dst); err != nil {
+		return err
+	}
+	if v, ok := dst.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) {
+				return err
+			}
+			return ErrBadRequest.WithDetails(err.Error()).Wrap(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package gex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type createUserRequest struct {
+	ID    int    `path:"id"`
+	Name  string `json:"name" validate:"required,max=5"`
+	Email string `json:"email" validate:"email"`
+	Role  string `query:"role" validate:"oneof=admin user"`
+}
+
+func (r *createUserRequest) Validate() error {
+	switch r.Name {
+	case "taken":
+		return errors.New("name is taken")
+	case "gone":
+		return ErrConflict
+	}
+	return nil
+}
+
+type createUserResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+func TestHandle(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("POST /users/{id}", Handle(func(ctx context.Context, req *createUserRequest) (*createUserResponse, error) {
+		return &createUserResponse{ID: req.ID, Name: req.Name, Role: req.Role}, nil
+	}))
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"binds body, path and query", "/users/7?role=admin", `{"name":"ann"}`, http.StatusOK, `{"id":7,"name":"ann","role":"admin"}`},
+		{"tag failures list fields", "/users/7?role=root", `{"name":"","email":"nope"}`, http.StatusBadRequest, `"field":"name"`},
+		{"path type failure", "/users/x", `{"name":"ann"}`, http.StatusBadRequest, `"rule":"type"`},
+		{"malformed JSON", "/users/7", `{`, http.StatusBadRequest, `"malformed JSON body"`},
+		{"plain Validator error is a bad request", "/users/7", `{"name":"taken"}`, http.StatusBadRequest, `"details":"name is taken"`},
+		{"HTTPError from Validator is kept", "/users/7", `{"name":"gone"}`, http.StatusConflict, `"conflict"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleRejectsInvalidValidateTags(t *testing.T) {
+	type nested struct {
+		N int `validate:"min=one"`
+	}
+
+	tests := []struct {
+		name  string
+		build func()
+	}{
+		{"unknown rule", func() {
+			Handle(func(context.Context, *struct {
+				A string `validate:"requird"`
+			}) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+		{"malformed limit", func() {
+			Handle(func(context.Context, *struct {
+				A string `validate:"max=ten"`
+			}) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+		{"empty oneof", func() {
+			Handle(func(context.Context, *struct {
+				A string `validate:"oneof="`
+			}) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+		{"min on a bool", func() {
+			Handle(func(context.Context, *struct {
+				A bool `validate:"min=1"`
+			}) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+		{"max on a struct", func() {
+			Handle(func(context.Context, *struct {
+				A *struct{ B int } `validate:"max=1"`
+			}) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+		{"nested struct", func() {
+			Handle(func(context.Context, *struct{ In *nested }) (*struct{}, error) {
+				return nil, nil
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Handle did not panic")
+				}
+			}()
+			tt.build()
+		})
+	}
+}
+
+func TestValidateReturnsInvalidTagError(t *testing.T) {
+	v := struct {
+		A string `validate:"requird"`
+	}{}
+
+	err := Validate(&v)
+	if err == nil || AsHTTPError(err).Status != http.StatusInternalServerError {
+		t.Fatalf("Validate = %v, want an internal error", err)
+	}
+}
+
+func TestValidateDetails(t *testing.T) {
+	v := createUserRequest{Name: "toolong", Email: "a@b.example", Role: "user"}
+
+	httpErr := AsHTTPError(Validate(&v))
+	details, _ := json.Marshal(httpErr.Details)
+	if want := `[{"field":"name","rule":"max","message":"length must be at most 5"}]`; string(details) != want {
+		t.Errorf("details = %s, want %s", details, want)
+	}
+}
+
+type Pagination struct {
+	Limit int `query:"limit" validate:"max=100"`
+}
+
+type Tenant struct {
+	TenantID string `path:"tenant"`
+}
+
+type listItemsRequest struct {
+	Pagination
+	*Tenant
+	Sort string `query:"sort"`
+}
+
+func TestHandleBindsEmbeddedFields(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /{tenant}/items", Handle(func(ctx context.Context, req *listItemsRequest) (*listItemsRequest, error) {
+		return req, nil
+	}))
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"promoted fields", "/acme/items?limit=10&sort=name", http.StatusOK, `{"Limit":10,"TenantID":"acme","Sort":"name"}`},
+		{"promoted field validated by its own name", "/acme/items?limit=500", http.StatusBadRequest, `"field":"limit","rule":"max"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
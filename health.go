@@ -0,0 +1,184 @@
+package gex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is used when a health check is added with a zero timeout
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// HealthCheck reports a problem by returning an error.
+// It should give up when ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name    string
+	timeout time.Duration
+	check   HealthCheck
+}
+
+type healthChecks struct {
+	mu        sync.RWMutex
+	liveness  []namedHealthCheck
+	readiness []namedHealthCheck
+}
+
+// HealthReport is the JSON body served by the health endpoints
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+
+	// Serving is set by the readiness endpoints: false while the app is
+	// not serving or is draining, which fails the report
+	Serving *bool `json:"serving,omitempty"`
+}
+
+// HealthCheckResult is the outcome of one named check
+type HealthCheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// AddLivenessCheck registers a check for the liveness endpoint.
+// A failing liveness check means the process should be restarted.
+func (a *App) AddLivenessCheck(name string, timeout time.Duration, check HealthCheck) {
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+
+	a.health.liveness = append(a.health.liveness, namedHealthCheck{name: name, timeout: timeout, check: check})
+}
+
+// AddReadinessCheck registers a check for the readiness endpoint, e.g. a DB ping.
+// A failing readiness check means the app should not receive traffic.
+func (a *App) AddReadinessCheck(name string, timeout time.Duration, check HealthCheck) {
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+
+	a.health.readiness = append(a.health.readiness, namedHealthCheck{name: name, timeout: timeout, check: check})
+}
+
+// EnableHealth registers the health endpoints under path:
+//
+//	GET {path}        all checks
+//	GET {path}/livez  liveness checks
+//	GET {path}/readyz readiness checks, failing while the app is not serving or is draining
+//
+// Each responds 200 when every check passes and 503 otherwise.
+func (a *App) EnableHealth(path string) {
+	a.registerHealth(path, func(pattern string, handler http.HandlerFunc) {
+		a.AddRoute(pattern, handler)
+	})
+}
+
+// registerHealth adds the health endpoints under path through addRoute
+func (a *App) registerHealth(path string, addRoute func(pattern string, handler http.HandlerFunc)) {
+	prefix := cleanPrefix(path)
+	root := prefix
+	if root == "" {
+		root = "/{$}"
+	}
+
+	addRoute("GET "+root, func(w http.ResponseWriter, r *http.Request) {
+		a.writeHealth(w, r, true, true)
+	})
+	addRoute("GET "+prefix+"/livez", func(w http.ResponseWriter, r *http.Request) {
+		a.writeHealth(w, r, true, false)
+	})
+	addRoute("GET "+prefix+"/readyz", func(w http.ResponseWriter, r *http.Request) {
+		a.writeHealth(w, r, false, true)
+	})
+}
+
+func (a *App) writeHealth(w http.ResponseWriter, r *http.Request, liveness, readiness bool) {
+	a.health.mu.RLock()
+	var checks []namedHealthCheck
+	if liveness {
+		checks = append(checks, a.health.liveness...)
+	}
+	if readiness {
+		checks = append(checks, a.health.readiness...)
+	}
+	a.health.mu.RUnlock()
+
+	report := runHealthChecks(r.Context(), checks)
+	if readiness {
+		serving := a.IsReady()
+		report.Serving = &serving
+		if !serving {
+			report.Status = "fail"
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, status, report)
+}
+
+// runHealthChecks runs checks concurrently, each bounded by its own timeout
+func runHealthChecks(ctx context.Context, checks []namedHealthCheck) HealthReport {
+	report := HealthReport{Status: "ok", Checks: make(map[string]HealthCheckResult, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := runHealthCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != "ok" {
+				report.Status = "fail"
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func runHealthCheck(ctx context.Context, c namedHealthCheck) HealthCheckResult {
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Run in a goroutine so a check that ignores ctx still can't hang the endpoint
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := HealthCheckResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package gex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getHealth(t *testing.T, app *App, path string) (int, HealthReport) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%s body %q: %v", path, rec.Body, err)
+	}
+	return rec.Code, report
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("db down") }
+
+	tests := []struct {
+		name       string
+		readiness  HealthCheck
+		serving    bool
+		path       string
+		wantStatus int
+		wantChecks []string
+		wantServe  *bool
+	}{
+		{"liveness ignores readiness", failing, false, "/health/livez", http.StatusOK, []string{"goroutines"}, nil},
+		{"readiness passes", ok, true, "/health/readyz", http.StatusOK, []string{"server"}, new(bool)},
+		{"readiness check fails", failing, true, "/health/readyz", http.StatusServiceUnavailable, []string{"server"}, new(bool)},
+		{"not serving", ok, false, "/health/readyz", http.StatusServiceUnavailable, []string{"server"}, new(bool)},
+		{"all checks", ok, true, "/health", http.StatusOK, []string{"goroutines", "server"}, new(bool)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(HostConfig{}, http.NotFound)
+			app.AddLivenessCheck("goroutines", 0, ok)
+			// A user check may be called "server" too
+			app.AddReadinessCheck("server", 0, tt.readiness)
+			app.EnableHealth("/health")
+			app.ready.Store(tt.serving)
+
+			status, report := getHealth(t, app, tt.path)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%+v)", status, tt.wantStatus, report)
+			}
+			if len(report.Checks) != len(tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", report.Checks, tt.wantChecks)
+			}
+			for _, name := range tt.wantChecks {
+				if _, ok := report.Checks[name]; !ok {
+					t.Errorf("check %s missing from %v", name, report.Checks)
+				}
+			}
+			if (report.Serving == nil) != (tt.wantServe == nil) || (report.Serving != nil && *report.Serving != tt.serving) {
+				t.Errorf("serving = %v, want it reported %v", report.Serving, tt.wantServe != nil)
+			}
+		})
+	}
+}
+
+func TestHealthCheckTimeouts(t *testing.T) {
+	app := NewApp(HostConfig{}, http.NotFound)
+	app.AddLivenessCheck("ignores ctx", 20*time.Millisecond, func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	app.AddLivenessCheck("honours ctx", 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	app.AddLivenessCheck("fast", time.Second, func(context.Context) error { return nil })
+	app.AddLivenessCheck("panics", 0, func(context.Context) error { panic("boom") })
+	app.EnableHealth("/")
+
+	start := time.Now()
+	status, report := getHealth(t, app, "/livez")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("health took %v, want each check bounded by its timeout", elapsed)
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", status)
+	}
+	for name, want := range map[string]string{"ignores ctx": "fail", "honours ctx": "fail", "fast": "ok", "panics": "fail"} {
+		if got := report.Checks[name].Status; got != want {
+			t.Errorf("%s = %s (%s), want %s", name, got, report.Checks[name].Error, want)
+		}
+	}
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0", ShutdownDelay: 200 * time.Millisecond}, http.NotFound)
+	app.EnableHealth("/health")
+	startApp(t, app)
+
+	if status, _ := getHealth(t, app, "/health/readyz"); status != http.StatusOK {
+		t.Fatalf("readyz while serving = %d, want 200", status)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- app.Shutdown(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+
+	// Still inside ShutdownDelay: serving requests, but not ready
+	status, report := getHealth(t, app, "/health/readyz")
+	if status != http.StatusServiceUnavailable || report.Serving == nil || *report.Serving {
+		t.Errorf("readyz while draining = %d %+v, want 503 and serving false", status, report)
+	}
+	if status, _ := getHealth(t, app, "/health/livez"); status != http.StatusOK {
+		t.Errorf("livez while draining = %d, want 200", status)
+	}
+	<-done
+}
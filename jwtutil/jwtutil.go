@@ -12,10 +12,10 @@ import (
 
 type CustomClaims struct {
 	jwt.StandardClaims
-	SessionKey string `json:"session_key"` // Key used to get user's session data
-	// Role        string `json:"role"`
-	// IsSecure    bool   `json:"is_secure"`
-	// Requires2FA bool   `json:"requires_2fa"`
+	SessionKey  string `json:"session_key"` // Key used to get user's session data
+	Role        string `json:"role,omitempty"`
+	IsSecure    bool   `json:"is_secure,omitempty"`
+	Requires2FA bool   `json:"requires_2fa,omitempty"`
 }
 
 func (c CustomClaims) Valid() error {
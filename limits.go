@@ -0,0 +1,159 @@
+package gex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// MaxBytesMiddleware caps request bodies at n bytes.
+// Reading past the cap fails with an *http.MaxBytesError.
+func MaxBytesMiddleware(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				WriteError(w, r, ErrRequestTooLarge)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TimeoutMiddleware bounds the time a handler may run.
+// The handler's context is cancelled after d. If the handler has not finished by then
+// the client gets a JSON 504. If the client disconnects first, the request context is
+// cancelled and a JSON 503 is written, though the client will rarely read it.
+// Output written by the handler is buffered until it returns. A panic in the
+// handler is re-raised with the handler's stack attached.
+func TimeoutMiddleware(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, h: make(http.Header)}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+							panicChan <- p
+							return
+						}
+						panicChan <- &handlerPanic{value: p, stack: debug.Stack()}
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.flush()
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				// The handler may have finished just as the context ended
+				select {
+				case p := <-panicChan:
+					panic(p)
+				case <-done:
+					tw.flush()
+					return
+				default:
+				}
+
+				tw.expired = true
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					WriteError(w, r, ErrGatewayTimeout)
+				} else {
+					WriteError(w, r, ErrUnavailable)
+				}
+			}
+		})
+	}
+}
+
+// handlerPanic is a panic recovered from a handler running on another
+// goroutine and raised again on the request's goroutine. stack is where the
+// handler panicked; RecoveryMiddleware reports it instead of its own.
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+func (p *handlerPanic) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// Unwrap returns the panic value if it is an error
+func (p *handlerPanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+// timeoutWriter buffers a handler's response so it can be discarded on timeout
+type timeoutWriter struct {
+	w    http.ResponseWriter
+	h    http.Header
+	buf  bytes.Buffer
+	code int
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// flush copies the buffered response to the underlying writer. Caller holds mu.
+func (tw *timeoutWriter) flush() {
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.code)
+	tw.w.Write(tw.buf.Bytes())
+}
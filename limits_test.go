@@ -0,0 +1,108 @@
+package gex
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "fast handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("ok"))
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "ok",
+		},
+		{
+			name: "slow handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.Write([]byte("late"))
+			},
+			wantStatus: http.StatusGatewayTimeout,
+			wantBody:   `"timeout"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			TimeoutMiddleware(50*time.Millisecond)(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddlewareClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := TimeoutMiddleware(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestTimeoutMiddlewarePanicCarriesHandlerStack(t *testing.T) {
+	var gotValue any
+	var gotStack []byte
+	h := RecoveryMiddleware(RecoveryConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		OnPanic: func(r *http.Request, recovered any, stack []byte) {
+			gotValue, gotStack = recovered, stack
+		},
+	})(TimeoutMiddleware(time.Minute)(http.HandlerFunc(panickingHandler)))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if gotValue != "boom" {
+		t.Errorf("recovered = %v, want boom", gotValue)
+	}
+	if !strings.Contains(string(gotStack), "panickingHandler") {
+		t.Errorf("stack does not show the handler:\n%s", gotStack)
+	}
+}
+
+func TestTimeoutMiddlewareAbortHandler(t *testing.T) {
+	h := TimeoutMiddleware(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
@@ -0,0 +1,194 @@
+package gex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+)
+
+// DefaultAdminHost is the interface the admin listener binds to when
+// HostConfig.AdminHost is empty, keeping it off public networks
+const DefaultAdminHost = "127.0.0.1"
+
+// auxServer is a listener the app runs next to the main one, e.g. the HTTPS
+// redirect or the admin port. It shares the app's shutdown.
+type auxServer struct {
+	name     string
+	server   *http.Server
+	listener net.Listener
+}
+
+// Admin holds the routes served on the internal admin listener,
+// see HostConfig.AdminPort. It has its own mux; app middleware does not apply.
+type Admin struct {
+	app *App
+	mux *http.ServeMux
+}
+
+// Admin returns the admin route set. Routes are only served when
+// HostConfig.AdminPort is set.
+func (a *App) Admin() *Admin {
+	return a.admin
+}
+
+// AddRoute registers a route on the admin listener
+func (ad *Admin) AddRoute(pattern string, handler http.HandlerFunc) {
+	ad.mux.Handle(pattern, handler)
+}
+
+// EnableMetrics records app metrics like App.EnableMetrics,
+// but serves them on the admin listener
+func (ad *Admin) EnableMetrics(path string) *PrometheusMetrics {
+	m := NewPrometheusMetrics()
+	ad.app.SetMetricsRecorder(m)
+	ad.AddRoute("GET "+path, m.Registry.Handler().ServeHTTP)
+	return m
+}
+
+// EnableHealth serves the app health endpoints on the admin listener,
+// see App.EnableHealth
+func (ad *Admin) EnableHealth(path string) {
+	ad.app.registerHealth(path, ad.AddRoute)
+}
+
+// EnablePprof serves the net/http/pprof profiles under /debug/pprof/
+func (ad *Admin) EnablePprof() {
+	ad.AddRoute("/debug/pprof/", pprof.Index)
+	ad.AddRoute("/debug/pprof/cmdline", pprof.Cmdline)
+	ad.AddRoute("/debug/pprof/profile", pprof.Profile)
+	ad.AddRoute("/debug/pprof/symbol", pprof.Symbol)
+	ad.AddRoute("/debug/pprof/trace", pprof.Trace)
+}
+
+// AdminAddr returns the address of the admin listener once it is listening
+func (a *App) AdminAddr() string {
+	return a.auxAddr("admin")
+}
+
+// RedirectAddr returns the address of the HTTPS redirect listener once it is listening
+func (a *App) RedirectAddr() string {
+	return a.auxAddr("redirect")
+}
+
+func (a *App) auxAddr(name string) string {
+	a.listenerMu.Lock()
+	defer a.listenerMu.Unlock()
+
+	for _, aux := range a.aux {
+		if aux.name == name {
+			return aux.listener.Addr().String()
+		}
+	}
+	return ""
+}
+
+// listenAux opens the redirect and admin listeners configured in HostConfig
+func (a *App) listenAux() ([]*auxServer, error) {
+	hc := a.HostConfig
+
+	var servers []*auxServer
+	if hc.RedirectPort != "" {
+		if !a.tlsEnabled() {
+			return nil, errors.New("RedirectPort is set but TLS is not configured")
+		}
+		servers = append(servers, &auxServer{
+			name:   "redirect",
+			server: a.newServer(net.JoinHostPort(hc.ServerHost, hc.RedirectPort), a.redirectHandler()),
+		})
+	}
+	if hc.AdminPort != "" {
+		host := hc.AdminHost
+		if host == "" {
+			host = DefaultAdminHost
+		}
+		servers = append(servers, &auxServer{
+			name:   "admin",
+			server: a.newServer(net.JoinHostPort(host, hc.AdminPort), a.admin.mux),
+		})
+	}
+
+	for i, aux := range servers {
+		ln, inherited, err := inheritedListener(aux.name)
+		if !inherited && err == nil {
+			ln, err = net.Listen("tcp", aux.server.Addr)
+		}
+		if err != nil {
+			for _, opened := range servers[:i] {
+				opened.listener.Close()
+			}
+			return nil, fmt.Errorf("%s listen on %s failed: %w", aux.name, aux.server.Addr, err)
+		}
+		aux.listener = ln
+	}
+
+	a.listenerMu.Lock()
+	a.aux = servers
+	a.listenerMu.Unlock()
+
+	return servers, nil
+}
+
+// redirectHandler sends plain HTTP requests to the HTTPS listener,
+// except ACME challenges which go to HostConfig.ACMEChallengeHandler
+func (a *App) redirectHandler() http.Handler {
+	mux := http.NewServeMux()
+	if h := a.HostConfig.ACMEChallengeHandler; h != nil {
+		mux.Handle("/.well-known/acme-challenge/", h)
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		host := stripPort(r.Host)
+		if host == "" {
+			WriteError(w, r, ErrBadRequest)
+			return
+		}
+		if _, port, err := net.SplitHostPort(a.Addr()); err == nil && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 literal
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+	return mux
+}
+
+// shutdownServers drains the main and auxiliary servers concurrently
+func (a *App) shutdownServers(ctx context.Context) []error {
+	a.listenerMu.Lock()
+	servers := []*http.Server{a.server}
+	for _, aux := range a.aux {
+		servers = append(servers, aux.server)
+	}
+	a.listenerMu.Unlock()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := server.Shutdown(ctx); err != nil {
+				server.Close()
+
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, fmt.Errorf("server %s shutdown failed: %w", server.Addr, err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,115 @@
+package gex
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	acme := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("token"))
+	})
+
+	tests := []struct {
+		name         string
+		httpsPort    string
+		method       string
+		host         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"GET keeps path and query", "8443", http.MethodGet, "example.com:8080", "/a/b?c=1", http.StatusMovedPermanently, "https://example.com:8443/a/b?c=1"},
+		{"POST keeps the method", "8443", http.MethodPost, "example.com", "/form", http.StatusPermanentRedirect, "https://example.com:8443/form"},
+		{"default port is dropped", "443", http.MethodGet, "example.com:80", "/", http.StatusMovedPermanently, "https://example.com/"},
+		{"IPv6 literal", "443", http.MethodHead, "[::1]:80", "/x", http.StatusMovedPermanently, "https://[::1]/x"},
+		{"IPv6 literal with port", "8443", http.MethodGet, "[::1]:80", "/x", http.StatusMovedPermanently, "https://[::1]:8443/x"},
+		{"missing host", "443", http.MethodGet, "", "/", http.StatusBadRequest, ""},
+		{"ACME challenge is answered", "443", http.MethodGet, "example.com", "/.well-known/acme-challenge/abc", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(HostConfig{ServerPort: tt.httpsPort, ACMEChallengeHandler: acme}, nil)
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			app.redirectHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0", AdminPort: "0"}, http.NotFound)
+	app.AddRoute("GET /public", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("public"))
+	})
+	app.Admin().AddRoute("GET /internal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+	<-app.Started()
+
+	if !strings.HasPrefix(app.AdminAddr(), DefaultAdminHost+":") {
+		t.Errorf("AdminAddr = %q, want it on %s", app.AdminAddr(), DefaultAdminHost)
+	}
+	if app.RedirectAddr() != "" {
+		t.Errorf("RedirectAddr = %q without RedirectPort", app.RedirectAddr())
+	}
+
+	tests := []struct {
+		addr, path string
+		wantStatus int
+		wantBody   string
+	}{
+		{app.AdminAddr(), "/internal", http.StatusOK, "internal"},
+		{app.AdminAddr(), "/public", http.StatusNotFound, ""},
+		{app.Addr(), "/public", http.StatusOK, "public"},
+		{app.Addr(), "/internal", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get("http://" + tt.addr + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s%s: status = %d, want %d", tt.addr, tt.path, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantBody != "" && string(body) != tt.wantBody {
+			t.Errorf("%s%s: body = %q, want %q", tt.addr, tt.path, body, tt.wantBody)
+		}
+	}
+
+	// Shutting the app down closes the admin listener as well
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if _, err := http.Get("http://" + app.AdminAddr() + "/internal"); err == nil {
+		t.Error("admin listener still serving after shutdown")
+	}
+}
+
+func TestRedirectPortRequiresTLS(t *testing.T) {
+	app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0", RedirectPort: "0"}, nil)
+	if err := app.Run(context.Background()); err == nil {
+		t.Error("Run succeeded with RedirectPort but no TLS")
+	}
+}
@@ -0,0 +1,140 @@
+package gex
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i247app/gex/metrics"
+	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// MetricsRecorder receives the measurements gex takes.
+// The built-in implementation renders Prometheus text; implement this
+// interface to feed another metrics library instead.
+type MetricsRecorder interface {
+	RequestStarted(r *http.Request)
+	RequestFinished(r *http.Request, pattern string, status int, duration time.Duration)
+	SessionAutoRefreshed()
+	JwtValidationFailed(reason string)
+}
+
+// PrometheusMetrics is the built-in MetricsRecorder, backed by a metrics.Registry
+type PrometheusMetrics struct {
+	Registry *metrics.Registry
+
+	requests       *metrics.CounterVec
+	latency        *metrics.HistogramVec
+	inFlight       *metrics.GaugeVec
+	sessionRefresh *metrics.CounterVec
+	jwtFailures    *metrics.CounterVec
+}
+
+// NewPrometheusMetrics creates the gex metrics in a new registry
+func NewPrometheusMetrics() *PrometheusMetrics {
+	reg := metrics.NewRegistry()
+	m := &PrometheusMetrics{
+		Registry:       reg,
+		requests:       reg.NewCounterVec("gex_http_requests_total", "HTTP requests by method, route pattern and status.", "method", "pattern", "status"),
+		latency:        reg.NewHistogramVec("gex_http_request_duration_seconds", "HTTP request latency by method and route pattern.", nil, "method", "pattern"),
+		inFlight:       reg.NewGaugeVec("gex_http_requests_in_flight", "HTTP requests currently being served."),
+		sessionRefresh: reg.NewCounterVec("gex_session_auto_refresh_total", "Expired sessions refreshed automatically."),
+		jwtFailures:    reg.NewCounterVec("gex_jwt_validation_failures_total", "Rejected request JWTs by reason.", "reason"),
+	}
+
+	// Report unlabelled series from the first scrape
+	m.inFlight.Add(0)
+	m.sessionRefresh.Add(0)
+
+	return m
+}
+
+func (m *PrometheusMetrics) RequestStarted(*http.Request) {
+	m.inFlight.Add(1)
+}
+
+func (m *PrometheusMetrics) RequestFinished(r *http.Request, pattern string, status int, duration time.Duration) {
+	m.inFlight.Add(-1)
+	method := methodLabel(r.Method)
+	m.requests.Inc(method, pattern, strconv.Itoa(status))
+	m.latency.Observe(duration.Seconds(), method, pattern)
+}
+
+// methodLabel returns method if it is a standard HTTP method, else "OTHER".
+// Clients choose the method, so passing it through unchecked would let them
+// create any number of series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func (m *PrometheusMetrics) SessionAutoRefreshed() {
+	m.sessionRefresh.Inc()
+}
+
+func (m *PrometheusMetrics) JwtValidationFailed(reason string) {
+	m.jwtFailures.Inc(reason)
+}
+
+// TrackSessions exports the number of sessions in c as gex_sessions_active
+func (m *PrometheusMetrics) TrackSessions(c *session.Container) {
+	m.Registry.NewGaugeFunc("gex_sessions_active", "Sessions held in the session container.", func() float64 {
+		return float64(c.Count())
+	})
+}
+
+// EnableMetrics records request, session and JWT metrics with the built-in
+// Prometheus recorder and serves them at path. It returns the recorder so
+// more metrics can be added, e.g. with TrackSessions.
+func (a *App) EnableMetrics(path string) *PrometheusMetrics {
+	m := NewPrometheusMetrics()
+	a.SetMetricsRecorder(m)
+	a.AddRoute("GET "+path, m.Registry.Handler().ServeHTTP).Hidden()
+	return m
+}
+
+// SetMetricsRecorder sends the app's measurements to rec. The recorder also receives
+// session refreshes and JWT validation failures from the session providers
+// serving the app's requests.
+func (a *App) SetMetricsRecorder(rec MetricsRecorder) {
+	a.metrics = rec
+	a.buildHandler()
+}
+
+// metricsMiddleware measures every request. Unmatched requests are labelled
+// "unmatched" rather than by path, which is client controlled. PrometheusMetrics
+// likewise labels non-standard methods "OTHER". Session provider events reach
+// rec through sessionprovider.Hooks in the request context.
+func metricsMiddleware(rec MetricsRecorder) Middleware {
+	hooks := &sessionprovider.Hooks{
+		JwtValidationFailure: rec.JwtValidationFailed,
+		SessionAutoRefresh:   rec.SessionAutoRefreshed,
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			st, r := withRequestState(r.WithContext(sessionprovider.ContextWithHooks(r.Context(), hooks)))
+			rw := newResponseWriter(w)
+
+			rec.RequestStarted(r)
+			defer func() {
+				pattern := st.routePattern()
+				if pattern == "" {
+					pattern = "unmatched"
+				}
+				status := rw.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				rec.RequestFinished(r, pattern, status, time.Since(start))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
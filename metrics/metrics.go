@@ -0,0 +1,359 @@
+// Package metrics is a small, dependency free metrics registry that renders
+// the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and writes them out
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w io.Writer, name string)
+	help() string
+	kind() string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// WriteText writes every metric in the Prometheus text format, sorted by name
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make(map[string]metric, len(r.metrics))
+	for name, m := range r.metrics {
+		metrics[name] = m
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		m := metrics[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(m.help()), name, m.kind()); err != nil {
+			return err
+		}
+		m.write(w, name)
+	}
+	return nil
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// vec is the label handling shared by every metric type
+type vec[T any] struct {
+	helpText string
+	labels   []string
+	newChild func() *T
+
+	mu       sync.Mutex
+	children map[string]*T
+	values   map[string][]string
+}
+
+func newVec[T any](help string, labels []string, newChild func() *T) vec[T] {
+	return vec[T]{
+		helpText: help,
+		labels:   labels,
+		newChild: newChild,
+		children: make(map[string]*T),
+		values:   make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	child, ok := v.children[key]
+	if !ok {
+		child = v.newChild()
+		v.children[key] = child
+		v.values[key] = append([]string{}, labelValues...)
+	}
+	return child
+}
+
+// each calls fn for every child in a stable order
+func (v *vec[T]) each(fn func(labels string, child *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	type entry struct {
+		labels string
+		child  *T
+	}
+	entries := make([]entry, len(keys))
+	for i, key := range keys {
+		entries[i] = entry{formatLabels(v.labels, v.values[key]), v.children[key]}
+	}
+	v.mu.Unlock()
+
+	for _, e := range entries {
+		fn(e.labels, e.child)
+	}
+}
+
+func (v *vec[T]) help() string {
+	return v.helpText
+}
+
+// CounterVec is a set of counters partitioned by labels
+type CounterVec struct {
+	vec[counter]
+}
+
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(help, labels, func() *counter { return &counter{} })}
+	r.register(name, c)
+	return c
+}
+
+// Add increases the counter for labelValues by delta, which must not be negative
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	child := c.with(labelValues)
+	child.mu.Lock()
+	child.value += delta
+	child.mu.Unlock()
+}
+
+// Inc increases the counter for labelValues by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) kind() string { return "counter" }
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	c.each(func(labels string, child *counter) {
+		child.mu.Lock()
+		value := child.value
+		child.mu.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+	})
+}
+
+// GaugeVec is a set of gauges partitioned by labels
+type GaugeVec struct {
+	vec[counter]
+}
+
+// NewGaugeVec registers a gauge with the given label names
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(help, labels, func() *counter { return &counter{} })}
+	r.register(name, g)
+	return g
+}
+
+// Add changes the gauge for labelValues by delta
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	child := g.with(labelValues)
+	child.mu.Lock()
+	child.value += delta
+	child.mu.Unlock()
+}
+
+// Set sets the gauge for labelValues
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	child := g.with(labelValues)
+	child.mu.Lock()
+	child.value = value
+	child.mu.Unlock()
+}
+
+func (g *GaugeVec) kind() string { return "gauge" }
+
+func (g *GaugeVec) write(w io.Writer, name string) {
+	g.each(func(labels string, child *counter) {
+		child.mu.Lock()
+		value := child.value
+		child.mu.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+	})
+}
+
+type gaugeFunc struct {
+	helpText string
+	fn       func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{helpText: help, fn: fn})
+}
+
+func (g *gaugeFunc) help() string { return g.helpText }
+func (g *gaugeFunc) kind() string { return "gauge" }
+
+func (g *gaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(g.fn()))
+}
+
+// HistogramVec is a set of histograms partitioned by labels
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds
+// (nil uses DefBuckets) and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{buckets: buckets}
+	h.vec = newVec(help, labels, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	r.register(name, h)
+	return h
+}
+
+// Observe records value in the histogram for labelValues
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	child := h.with(labelValues)
+	i := sort.SearchFloat64s(h.buckets, value)
+
+	child.mu.Lock()
+	defer child.mu.Unlock()
+
+	if i < len(child.counts) {
+		child.counts[i]++
+	}
+	child.count++
+	child.sum += value
+}
+
+func (h *HistogramVec) kind() string { return "histogram" }
+
+func (h *HistogramVec) write(w io.Writer, name string) {
+	h.each(func(labels string, child *histogram) {
+		child.mu.Lock()
+		counts := append([]uint64{}, child.counts...)
+		count, sum := child.count, child.sum
+		child.mu.Unlock()
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, count)
+	})
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", name, escapeLabel(values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends one label to an already formatted label set
+func withLabel(labels, name, value string) string {
+	pair := fmt.Sprintf("%s=%q", name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+// escapeLabel leaves only the escapes the text format allows: \\, \" and \n.
+// %q adds the quotes and escapes those three; other control characters are dropped first.
+func escapeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
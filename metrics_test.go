@@ -0,0 +1,86 @@
+package gex
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i247app/gex/jwtutil"
+	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/sessionprovider"
+)
+
+func TestMetricsLabelsBoundedByRouteAndMethod(t *testing.T) {
+	m := NewPrometheusMetrics()
+	h := metricsMiddleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, method := range []string{http.MethodGet, "FOO", "BAR"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/random/path", nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`gex_http_requests_total{method="GET",pattern="unmatched",status="200"} 1`,
+		`gex_http_requests_total{method="OTHER",pattern="unmatched",status="200"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+	for _, leak := range []string{"FOO", "BAR", "/random/path"} {
+		if strings.Contains(string(body), leak) {
+			t.Errorf("metrics contain client controlled label %q", leak)
+		}
+	}
+}
+
+func TestMetricsJwtFailuresPerApp(t *testing.T) {
+	helper, err := jwtutil.NewHmacJwtHelper([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := sessionprovider.NewJwtSessionProvider(session.NewContainer(), helper, func() session.SessionStorer {
+		return session.NewInMemorySession()
+	}, time.Hour)
+
+	newApp := func() (*App, *PrometheusMetrics) {
+		app := NewApp(HostConfig{}, nil)
+		app.RegisterMiddleware(SessionMiddleware(provider))
+		app.AddRoute("GET /", func(w http.ResponseWriter, r *http.Request) {})
+		return app, app.EnableMetrics("/metrics")
+	}
+	scrape := func(m *PrometheusMetrics) string {
+		rec := httptest.NewRecorder()
+		m.Registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+	appA, metricsA := newApp()
+	_, metricsB := newApp()
+
+	for _, auth := range []string{"", "Bearer ", "Bearer not-a-jwt"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		appA.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	bodyA, bodyB := scrape(metricsA), scrape(metricsB)
+	if want := `gex_jwt_validation_failures_total{reason="malformed"} 1`; !strings.Contains(bodyA, want) {
+		t.Errorf("app A metrics missing %s:\n%s", want, bodyA)
+	}
+	for _, reason := range []string{"missing", "empty"} {
+		if strings.Contains(bodyA, `reason="`+reason+`"`) {
+			t.Errorf("app A counts requests without a token as %s failures", reason)
+		}
+	}
+	if strings.Contains(bodyB, "gex_jwt_validation_failures_total{") {
+		t.Errorf("app B counts app A's failures:\n%s", bodyB)
+	}
+}
@@ -1,29 +1,201 @@
 package gex
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TrailingSlashPolicy decides what happens to a request whose path only
+// matches a route once a trailing slash is removed, e.g. "/users/" for "/users"
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashNone leaves such requests unmatched (404)
+	TrailingSlashNone TrailingSlashPolicy = iota
+	// TrailingSlashRedirect redirects to the path without the slash, keeping the method
+	TrailingSlashRedirect
+	// TrailingSlashStrip serves the route directly as if the slash was not there
+	TrailingSlashStrip
+)
+
+// RoutingConfig customizes how requests that match no route are answered
+type RoutingConfig struct {
+	// NotFound handles requests matching no route. Defaults to the
+	// defaultRoute passed to NewApp, then to a JSON ErrNotFound.
+	NotFound http.Handler
+
+	// MethodNotAllowed handles requests whose path matches a route but not
+	// its method. The Allow header is set before it runs.
+	// Defaults to a JSON ErrMethodNotAllowed.
+	MethodNotAllowed http.Handler
+
+	TrailingSlash TrailingSlashPolicy
+
+	// AutoOptions answers OPTIONS requests for paths without an OPTIONS route
+	// with 204 and the Allow header
+	AutoOptions bool
+}
 
 type gexMux struct {
-	mux            *http.ServeMux
-	defaultHandler http.HandlerFunc
+	mux              *http.ServeMux
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+	trailingSlash    TrailingSlashPolicy
+	autoOptions      bool
+
+	routesMu sync.Mutex
+	routes   []*routeEntry
+}
+
+func newGexMux(cfg RoutingConfig, defaultRoute http.HandlerFunc) *gexMux {
+	m := &gexMux{
+		mux:              http.NewServeMux(),
+		notFound:         cfg.NotFound,
+		methodNotAllowed: cfg.MethodNotAllowed,
+		trailingSlash:    cfg.TrailingSlash,
+		autoOptions:      cfg.AutoOptions,
+	}
+	if m.notFound == nil && defaultRoute != nil {
+		m.notFound = defaultRoute
+	}
+	if m.notFound == nil {
+		m.notFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, ErrNotFound)
+		})
+	}
+	if m.methodNotAllowed == nil {
+		m.methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, ErrMethodNotAllowed)
+		})
+	}
+	return m
 }
 
 func (m *gexMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Check if the route matches any registered patterns
-	_, pattern := m.mux.Handler(r)
-	if pattern == "" {
-		// If no match, call the default handler
-		m.defaultHandler(w, r)
+	// Matched routes unwrap mw and record their pattern, see route.
+	// Only the mux's own 404 and 405 responses are held back here.
+	mw := &muxWriter{ResponseWriter: w, header: make(http.Header)}
+	m.mux.ServeHTTP(mw, r)
+
+	switch mw.status {
+	case http.StatusNotFound:
+		m.serveNotFound(w, r)
+	case http.StatusMethodNotAllowed:
+		allow := mw.header.Get("Allow")
+		if m.autoOptions && !slices.Contains(strings.Split(allow, ", "), http.MethodOptions) {
+			allow += ", " + http.MethodOptions
+		}
+		w.Header().Set("Allow", allow)
+
+		if m.autoOptions && r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		m.methodNotAllowed.ServeHTTP(w, r)
+	}
+}
+
+// serveNotFound applies the trailing slash policy before giving up
+func (m *gexMux) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if m.trailingSlash == TrailingSlashNone || len(path) < 2 || !strings.HasSuffix(path, "/") {
+		m.notFound.ServeHTTP(w, r)
 		return
 	}
 
-	// Otherwise, use the regular ServeMux to handle the request
-	m.mux.ServeHTTP(w, r)
+	stripped := r.Clone(r.Context())
+	stripped.URL.Path = strings.TrimRight(path, "/")
+	stripped.URL.RawPath = ""
+	if stripped.URL.Path == "" {
+		m.notFound.ServeHTTP(w, r)
+		return
+	}
+	if !m.matchesPath(w, stripped) {
+		m.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	if m.trailingSlash == TrailingSlashRedirect {
+		http.Redirect(w, r, stripped.URL.RequestURI(), http.StatusPermanentRedirect)
+		return
+	}
+	stripped.RequestURI = stripped.URL.RequestURI()
+	m.ServeHTTP(w, stripped)
+}
+
+// matchesPath reports whether r's path matches a route, whatever its method
+func (m *gexMux) matchesPath(w http.ResponseWriter, r *http.Request) bool {
+	h, pattern := m.mux.Handler(r)
+	if pattern != "" {
+		return true
+	}
+	// Without a pattern h is the mux's own 404 or 405 handler, which a
+	// muxWriter captures without writing to w
+	probe := &muxWriter{ResponseWriter: w, header: make(http.Header)}
+	h.ServeHTTP(probe, r)
+	return probe.status == http.StatusMethodNotAllowed
+}
+
+func (m *gexMux) addRoute(pattern string, handler http.Handler, chain Chain) *Route {
+	// Wrap as Chain.Then does, describing each middleware from the handler it builds
+	h := handler
+	middleware := make([]middlewareInfo, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+		middleware[i] = describeMiddleware(chain[i], h)
+	}
+	m.mux.Handle(pattern, route(h))
+
+	entry := &routeEntry{pattern: pattern, handler: handler, middleware: middleware}
+	m.routesMu.Lock()
+	m.routes = append(m.routes, entry)
+	m.routesMu.Unlock()
+
+	return &Route{mux: m, entry: entry}
+}
+
+// route records the matched pattern and hands the route the real writer
+func route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mw, ok := w.(*muxWriter); ok {
+			w = mw.ResponseWriter
+		}
+		if st := getRequestState(r.Context()); st != nil {
+			st.setPattern(r.Pattern)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// muxWriter sees only responses the ServeMux writes itself: its 404 and 405
+// are captured for gexMux to answer, anything else (path clean redirects)
+// passes through
+type muxWriter struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+}
+
+func (w *muxWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *muxWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		w.status = status
+		return
+	}
+	for k, v := range w.header {
+		w.ResponseWriter.Header()[k] = v
+	}
+	w.ResponseWriter.WriteHeader(status)
 }
 
-func (m *gexMux) addRoute(path string, handler http.Handler, middleware ...Middleware) {
-	// fmt.Printf("registering: %v\n", path)
-	for _, mw := range middleware {
-		handler = mw(handler).(http.HandlerFunc)
+func (w *muxWriter) Write(b []byte) (int, error) {
+	if w.status != 0 {
+		return len(b), nil
 	}
-	m.mux.HandleFunc(path, http.HandlerFunc(handler.ServeHTTP))
+	return w.ResponseWriter.Write(b)
 }
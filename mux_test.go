@@ -0,0 +1,140 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestMuxApp(cfg RoutingConfig) *App {
+	app := NewApp(HostConfig{Routing: cfg}, nil)
+	app.AddRoute("GET /users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	app.AddRoute("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + r.PathValue("id")))
+	})
+	app.AddRoute("PUT /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	app.AddRoute("GET /files/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("files"))
+	})
+	app.AddRoute("GET /cors", func(w http.ResponseWriter, r *http.Request) {})
+	app.AddRoute("OPTIONS /cors", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("own options"))
+	})
+	return app
+}
+
+func TestMux(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          RoutingConfig
+		method       string
+		target       string
+		wantStatus   int
+		wantBody     string
+		wantAllow    string
+		wantLocation string
+	}{
+		{name: "path value", method: "GET", target: "/users/42", wantStatus: 200, wantBody: "user 42"},
+		{name: "not found", method: "GET", target: "/nope", wantStatus: 404, wantBody: `"not_found"`},
+		{name: "method not allowed", method: "POST", target: "/users/42", wantStatus: 405, wantBody: `"method_not_allowed"`, wantAllow: "GET, HEAD, PUT"},
+		{name: "OPTIONS without AutoOptions", method: "OPTIONS", target: "/users/42", wantStatus: 405, wantAllow: "GET, HEAD, PUT"},
+		{
+			name: "AutoOptions adds OPTIONS to Allow", cfg: RoutingConfig{AutoOptions: true},
+			method: "POST", target: "/users/42", wantStatus: 405, wantAllow: "GET, HEAD, PUT, OPTIONS",
+		},
+		{
+			name: "AutoOptions answers OPTIONS", cfg: RoutingConfig{AutoOptions: true},
+			method: "OPTIONS", target: "/users/42", wantStatus: 204, wantAllow: "GET, HEAD, PUT, OPTIONS",
+		},
+		{
+			name: "AutoOptions keeps an OPTIONS route", cfg: RoutingConfig{AutoOptions: true},
+			method: "OPTIONS", target: "/cors", wantStatus: 200, wantBody: "own options",
+		},
+		{
+			name: "AutoOptions does not repeat OPTIONS", cfg: RoutingConfig{AutoOptions: true},
+			method: "POST", target: "/cors", wantStatus: 405, wantAllow: "GET, HEAD, OPTIONS",
+		},
+		{name: "trailing slash unmatched by default", method: "GET", target: "/users/", wantStatus: 404},
+		{
+			name: "trailing slash redirect", cfg: RoutingConfig{TrailingSlash: TrailingSlashRedirect},
+			method: "GET", target: "/users/42/?x=1", wantStatus: 308, wantLocation: "/users/42?x=1",
+		},
+		{
+			name: "trailing slash redirect keeps unmatched paths", cfg: RoutingConfig{TrailingSlash: TrailingSlashRedirect},
+			method: "GET", target: "/nope/", wantStatus: 404,
+		},
+		{
+			name: "trailing slash strip", cfg: RoutingConfig{TrailingSlash: TrailingSlashStrip},
+			method: "GET", target: "/users/42/", wantStatus: 200, wantBody: "user 42",
+		},
+		{
+			name: "trailing slash strip then 405", cfg: RoutingConfig{TrailingSlash: TrailingSlashStrip},
+			method: "POST", target: "/users/42/", wantStatus: 405, wantAllow: "GET, HEAD, PUT",
+		},
+		{
+			name: "subtree route keeps its slash", cfg: RoutingConfig{TrailingSlash: TrailingSlashStrip},
+			method: "GET", target: "/files/", wantStatus: 200, wantBody: "files",
+		},
+		{name: "path clean redirect passes through", method: "GET", target: "/a/../users", wantStatus: 307, wantLocation: "/users"},
+		{name: "subtree redirect passes through", method: "GET", target: "/files", wantStatus: 307, wantLocation: "/files/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestMuxApp(tt.cfg)
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestMuxCustomHandlers(t *testing.T) {
+	app := newTestMuxApp(RoutingConfig{
+		NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+		MethodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("allow " + w.Header().Get("Allow")))
+		}),
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("NotFound status = %d, want 418", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users", nil))
+	if got := rec.Body.String(); got != "allow GET, HEAD" {
+		t.Errorf("MethodNotAllowed body = %q, want the Allow header set", got)
+	}
+}
+
+func TestMuxDefaultRoute(t *testing.T) {
+	app := NewApp(HostConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("default"))
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/anything", nil))
+	if rec.Body.String() != "default" {
+		t.Errorf("body = %q, want the default route", rec.Body)
+	}
+}
@@ -0,0 +1,475 @@
+package gex
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo is the info object of the generated OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPI generates an OpenAPI 3.1 document describing the app routes.
+//
+// Path parameters come from the route patterns. Query parameters, path
+// parameter types and the JSON body come from the type declared with
+// Route.Request, reading the same `path`, `query`, `json` and `validate` tags
+// as Handle. Routes without a method in their pattern and hidden routes are
+// left out.
+func (a *App) OpenAPI(info OpenAPIInfo) ([]byte, error) {
+	return json.Marshal(a.openAPIDocument(info))
+}
+
+// EnableOpenAPI serves the OpenAPI document at path, e.g. "/openapi.json".
+// It is generated on each request, so routes added later are included.
+func (a *App) EnableOpenAPI(path string, info OpenAPIInfo) {
+	a.AddRoute("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, a.openAPIDocument(info))
+	}).Hidden()
+}
+
+// OpenAPI 3.1 document objects, see https://spec.openapis.org/oas/v3.1.0
+type (
+	openAPIDocument struct {
+		OpenAPI    string                                  `json:"openapi"`
+		Info       OpenAPIInfo                             `json:"info"`
+		Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+		Components openAPIComponents                       `json:"components"`
+	}
+	openAPIComponents struct {
+		Schemas         map[string]*jsonSchema           `json:"schemas,omitempty"`
+		SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes,omitempty"`
+	}
+	openAPISecurityScheme struct {
+		Type         string `json:"type"`
+		Scheme       string `json:"scheme"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+	}
+	openAPIOperation struct {
+		OperationID string                     `json:"operationId"`
+		Summary     string                     `json:"summary,omitempty"`
+		Description string                     `json:"description,omitempty"`
+		Tags        []string                   `json:"tags,omitempty"`
+		Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+		RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+		Responses   map[string]openAPIResponse `json:"responses"`
+		Security    []map[string][]string      `json:"security,omitempty"`
+	}
+	openAPIParameter struct {
+		Name     string      `json:"name"`
+		In       string      `json:"in"`
+		Required bool        `json:"required,omitempty"`
+		Schema   *jsonSchema `json:"schema"`
+	}
+	openAPIRequestBody struct {
+		Required bool                        `json:"required"`
+		Content  map[string]openAPIMediaType `json:"content"`
+	}
+	openAPIResponse struct {
+		Description string                      `json:"description"`
+		Content     map[string]openAPIMediaType `json:"content,omitempty"`
+	}
+	openAPIMediaType struct {
+		Schema *jsonSchema `json:"schema"`
+	}
+)
+
+const bearerAuthScheme = "bearerAuth"
+
+// errorSchemaName names the error envelope schema. It is registered before
+// any user type, which then cannot take the name.
+const errorSchemaName = "gex.Error"
+
+func (a *App) openAPIDocument(info OpenAPIInfo) openAPIDocument {
+	gen := newSchemaGenerator()
+	gen.schemas[errorSchemaName] = gen.inlineSchema(reflect.TypeOf(errorBody{}))
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   make(map[string]map[string]*openAPIOperation),
+	}
+
+	errorResponse := openAPIResponse{
+		Description: "Error",
+		Content:     jsonContent(&jsonSchema{Ref: "#/components/schemas/" + errorSchemaName}),
+	}
+	var secured bool
+	operationIDs := make(map[string]int)
+
+	for _, e := range a.mux.snapshotRoutes() {
+		method, _, path := splitPattern(e.pattern)
+		if method == "" || e.doc.hidden {
+			continue
+		}
+
+		path, pathParams := openAPIPath(path)
+		op := &openAPIOperation{
+			OperationID: operationID(method, path, operationIDs),
+			Summary:     e.doc.summary,
+			Description: e.doc.description,
+			Tags:        e.doc.tags,
+			Responses:   map[string]openAPIResponse{"default": errorResponse},
+		}
+
+		// Parameters and body from the request type
+		req := e.doc.request
+		for req != nil && req.Kind() == reflect.Pointer {
+			req = req.Elem()
+		}
+		boundTypes := map[string]reflect.Type{}
+		if req != nil && req.Kind() == reflect.Struct {
+			for _, field := range reflect.VisibleFields(req) {
+				if !field.IsExported() {
+					continue
+				}
+				if name := field.Tag.Get("path"); name != "" {
+					boundTypes[name] = field.Type
+				} else if name := field.Tag.Get("query"); name != "" {
+					op.Parameters = append(op.Parameters, openAPIParameter{
+						Name:     name,
+						In:       "query",
+						Required: hasRule(field, "required"),
+						Schema:   gen.fieldSchema(field),
+					})
+				}
+			}
+			if body := gen.bodySchema(req); body != nil && method != http.MethodGet && method != http.MethodHead && method != http.MethodDelete {
+				op.RequestBody = &openAPIRequestBody{Required: true, Content: jsonContent(body)}
+			}
+			op.Responses["400"] = openAPIResponse{Description: "Invalid request", Content: errorResponse.Content}
+		}
+		params := make([]openAPIParameter, 0, len(pathParams)+len(op.Parameters))
+		for _, name := range pathParams {
+			schema := &jsonSchema{Type: "string"}
+			if t, ok := boundTypes[name]; ok {
+				schema = gen.schema(t)
+			}
+			params = append(params, openAPIParameter{Name: name, In: "path", Required: true, Schema: schema})
+		}
+		op.Parameters = append(params, op.Parameters...)
+
+		if e.doc.response != nil {
+			op.Responses["200"] = openAPIResponse{Description: "OK", Content: jsonContent(gen.schema(e.doc.response))}
+		} else {
+			op.Responses["200"] = openAPIResponse{Description: "OK"}
+		}
+
+		if e.doc.bearerAuth || hasGuard(e.middleware) {
+			secured = true
+			op.Security = []map[string][]string{{bearerAuthScheme: {}}}
+			op.Responses["401"] = openAPIResponse{Description: "Unauthorized", Content: errorResponse.Content}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	}
+
+	doc.Components.Schemas = gen.schemas
+	if secured {
+		doc.Components.SecuritySchemes = map[string]openAPISecurityScheme{
+			bearerAuthScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+	return doc
+}
+
+// hasGuard reports whether the route middleware include a guard, see GuardMiddleware
+func hasGuard(middleware []middlewareInfo) bool {
+	for _, mw := range middleware {
+		if mw.guard {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonContent(schema *jsonSchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]*)\}`)
+
+// openAPIPath converts a ServeMux path to an OpenAPI path and lists its
+// parameters: "{$}" is dropped and "{rest...}" becomes "{rest}"
+func openAPIPath(path string) (string, []string) {
+	path = strings.Replace(path, "{$}", "", 1)
+
+	var params []string
+	path = pathParamPattern.ReplaceAllStringFunc(path, func(m string) string {
+		name := strings.TrimSuffix(m[1:len(m)-1], "...")
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// operationID derives a unique id such as "get_users_id" from the method and path
+func operationID(method, path string, seen map[string]int) string {
+	name := strings.Trim(nonIdentifier.ReplaceAllString(path, "_"), "_")
+	if name == "" {
+		name = "root"
+	}
+	id := strings.ToLower(method) + "_" + name
+
+	seen[id]++
+	if n := seen[id]; n > 1 {
+		id += "_" + strconv.Itoa(n)
+	}
+	return id
+}
+
+// jsonSchema is the subset of JSON Schema 2020-12 the generator produces
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *float64               `json:"minLength,omitempty"`
+	MaxLength            *float64               `json:"maxLength,omitempty"`
+	MinItems             *float64               `json:"minItems,omitempty"`
+	MaxItems             *float64               `json:"maxItems,omitempty"`
+}
+
+// schemaGenerator builds schemas from Go types, collecting named structs
+// as components so they are described once and may refer to themselves
+type schemaGenerator struct {
+	schemas map[string]*jsonSchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*jsonSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schema returns the schema of t, a $ref for exported named structs
+func (g *schemaGenerator) schema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &jsonSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &jsonSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &jsonSchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &jsonSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &jsonSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &jsonSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", Format: "byte"}
+		}
+		return &jsonSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Array:
+		return &jsonSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" || !token.IsExported(t.Name()) {
+			return g.inlineSchema(t)
+		}
+		return &jsonSchema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &jsonSchema{}
+}
+
+// component registers the struct t under a unique name and returns the name
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	// Types sharing a name, e.g. echo.Request and login.Request, are told
+	// apart by their package
+	name := nonComponentName.ReplaceAllString(t.Name(), "_")
+	if g.schemas[name] != nil {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	for i, base := 2, name; g.schemas[name] != nil; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	// Register before filling in so recursive types end in a $ref
+	schema := &jsonSchema{}
+	g.names[t] = name
+	g.schemas[name] = schema
+	*schema = *g.inlineSchema(t)
+	return name
+}
+
+var nonComponentName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// inlineSchema describes struct t as an object following encoding/json field rules
+func (g *schemaGenerator) inlineSchema(t reflect.Type) *jsonSchema {
+	return g.objectSchema(t, false)
+}
+
+// bodySchema describes the JSON body of request type t: its fields without
+// path or query tags. It returns nil when no field is left.
+func (g *schemaGenerator) bodySchema(t reflect.Type) *jsonSchema {
+	bound := false
+	for _, field := range reflect.VisibleFields(t) {
+		if field.Tag.Get("path") != "" || field.Tag.Get("query") != "" {
+			bound = true
+			break
+		}
+	}
+
+	schema := g.objectSchema(t, true)
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	if !bound {
+		return g.schema(t)
+	}
+	return schema
+}
+
+func (g *schemaGenerator) objectSchema(t reflect.Type, skipBound bool) *jsonSchema {
+	schema := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if skipBound && (field.Tag.Get("path") != "" || field.Tag.Get("query") != "") {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a json name are flattened, as encoding/json does
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := g.objectSchema(ft, skipBound)
+			for k, v := range embedded.Properties {
+				if _, ok := schema.Properties[k]; !ok {
+					schema.Properties[k] = v
+				}
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		prop := g.fieldSchema(field)
+		if strings.Contains(opts, "string") && prop.Ref == "" {
+			prop = &jsonSchema{Type: "string"}
+		}
+		schema.Properties[name] = prop
+		if hasRule(field, "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// fieldSchema returns the schema of field with its validate rules applied
+func (g *schemaGenerator) fieldSchema(field reflect.StructField) *jsonSchema {
+	schema := g.schema(field.Type)
+	if schema.Ref != "" {
+		return schema
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		ruleName, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch ruleName {
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			var lower, upper **float64
+			switch schema.Type {
+			case "string":
+				lower, upper = &schema.MinLength, &schema.MaxLength
+			case "array":
+				lower, upper = &schema.MinItems, &schema.MaxItems
+			case "integer", "number":
+				lower, upper = &schema.Minimum, &schema.Maximum
+			default:
+				continue
+			}
+			if ruleName == "min" {
+				*lower = &n
+			} else {
+				*upper = &n
+			}
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				if n, err := strconv.ParseFloat(option, 64); err == nil && (schema.Type == "integer" || schema.Type == "number") {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, option)
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		}
+	}
+	return schema
+}
+
+// hasRule reports whether field's validate tag holds rule
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(field.Tag.Get("validate"), ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(r), "="); name == rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package gex
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAPISecuredByGuards(t *testing.T) {
+	requireTenant := func(tenant string) Middleware {
+		return GuardMiddleware("app.requireTenant", func(next http.Handler) http.Handler { return next })
+	}
+	logRequests := NamedMiddleware("app.logRequests", func(next http.Handler) http.Handler { return next })
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	app := NewApp(HostConfig{}, nil)
+	app.AddRoute("GET /public", noop, logRequests)
+	app.AddRoute("GET /admin", noop, RequireRole("admin"))
+	app.AddRoute("GET /me", noop, RequireAuthenticated())
+	app.AddRoute("GET /tenant", noop, requireTenant("acme"))
+	app.AddRoute("GET /annotated", noop).BearerAuth()
+	app.Group("/api", RequireSecure()).AddRoute("GET /keys", noop)
+
+	raw, err := app.OpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Security []map[string][]string `json:"security"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]bool{
+		"/public":    false,
+		"/admin":     true,
+		"/me":        true,
+		"/tenant":    true,
+		"/annotated": true,
+		"/api/keys":  true,
+	} {
+		op, ok := doc.Paths[path]["get"]
+		if !ok {
+			t.Errorf("%s missing from document", path)
+			continue
+		}
+		if got := len(op.Security) > 0; got != want {
+			t.Errorf("%s secured = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// Error is a user type sharing its name with the error envelope
+type Error struct {
+	Reason string `json:"reason"`
+}
+
+type searchRequest struct {
+	Pagination
+	Query string `query:"q" validate:"required"`
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	app := NewApp(HostConfig{}, nil)
+	app.AddRoute("GET /search", noop).Request(searchRequest{}).Response(Error{})
+
+	raw, err := app.OpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := doc.Components.Schemas["gex.Error"].Properties["error"]; !ok {
+		t.Errorf("gex.Error schema = %+v, want the error envelope", doc.Components.Schemas["gex.Error"])
+	}
+	if _, ok := doc.Components.Schemas["Error"].Properties["reason"]; !ok {
+		t.Errorf("Error schema = %+v, want the user type", doc.Components.Schemas["Error"])
+	}
+
+	// Promoted fields are parameters, as they are bound
+	params := map[string]bool{}
+	for _, p := range doc.Paths["/search"]["get"].Parameters {
+		if p.In == "query" {
+			params[p.Name] = p.Required
+		}
+	}
+	if want := map[string]bool{"limit": false, "q": true}; !maps.Equal(params, want) {
+		t.Errorf("query parameters = %v, want %v", params, want)
+	}
+}
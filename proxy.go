@@ -0,0 +1,213 @@
+package gex
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientInfo is where a request really came from, once proxy headers are resolved
+type clientInfo struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+type clientInfoKey struct{}
+
+// ProxyHeader selects which forwarding headers a trusted proxy sets.
+// Only that header is read; the other one is client controlled and ignored.
+type ProxyHeader int
+
+const (
+	// ProxyHeaderXForwarded reads X-Forwarded-For and X-Forwarded-Proto, as set
+	// by AWS ALB, nginx and most load balancers
+	ProxyHeaderXForwarded ProxyHeader = iota
+	// ProxyHeaderForwarded reads the RFC 7239 Forwarded header
+	ProxyHeaderForwarded
+)
+
+// ProxyConfig configures ProxyHeadersMiddleware
+type ProxyConfig struct {
+	// TrustedProxies lists the CIDRs or single IPs of the proxies
+	TrustedProxies []string
+
+	// Header is the forwarding header the proxies set. Zero is ProxyHeaderXForwarded.
+	Header ProxyHeader
+
+	// TrustHost honors the forwarded host (X-Forwarded-Host, or host= in
+	// Forwarded). Only enable it if the proxy sets or strips that value.
+	TrustHost bool
+}
+
+// ProxyHeadersMiddleware resolves the client IP, scheme and host of requests that
+// arrive through a trusted proxy, e.g. a load balancer. Forwarding headers are
+// only honored when the direct peer is trusted, only the one named by
+// cfg.Header is read, and the client is the first address, reading right to
+// left, that is not a trusted proxy. Read the results with ClientIP,
+// RequestScheme and RequestHost.
+func ProxyHeadersMiddleware(cfg ProxyConfig) Middleware {
+	trusted := mustParsePrefixes(cfg.TrustedProxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := resolveClient(r, trusted, cfg)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info)))
+		})
+	}
+}
+
+// ClientIP returns the client IP of r, resolved through trusted proxies when
+// ProxyHeadersMiddleware is in use, else the peer address
+func ClientIP(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.ip
+	}
+	return remoteIP(r)
+}
+
+// RequestScheme returns "https" or "http" as seen by the client
+func RequestScheme(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.scheme
+	}
+	return directScheme(r)
+}
+
+// RequestHost returns the host the client asked for
+func RequestHost(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.host
+	}
+	return r.Host
+}
+
+func resolveClient(r *http.Request, trusted []netip.Prefix, cfg ProxyConfig) clientInfo {
+	info := clientInfo{ip: remoteIP(r), scheme: directScheme(r), host: r.Host}
+	if !isTrusted(info.ip, trusted) {
+		return info
+	}
+
+	var hops []string
+	var proto, host string
+	switch cfg.Header {
+	case ProxyHeaderForwarded:
+		hops, proto, host = parseForwarded(r.Header.Values("Forwarded"))
+	default:
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		proto = lastValue(r.Header.Values("X-Forwarded-Proto"))
+		host = lastValue(r.Header.Values("X-Forwarded-Host"))
+	}
+	if !cfg.TrustHost {
+		host = ""
+	}
+
+	// Walk back from the nearest hop until we leave our own infrastructure
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := stripPort(hops[i])
+		if _, err := netip.ParseAddr(ip); err != nil {
+			break
+		}
+		info.ip = ip
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+
+	if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+		info.scheme = proto
+	}
+	if host != "" {
+		info.host = host
+	}
+	return info
+}
+
+// parseForwarded reads RFC 7239 Forwarded headers, returning the for= hops in
+// order and the proto and host of the nearest element that sets them
+func parseForwarded(values []string) (hops []string, proto, host string) {
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hops = append(hops, val)
+				case "proto":
+					proto = val
+				case "host":
+					host = val
+				}
+			}
+		}
+	}
+	return hops, proto, host
+}
+
+func mustParsePrefixes(cidrs []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				panic(fmt.Sprintf("gex: invalid trusted proxy %q: %v", cidr, err))
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("gex: invalid trusted proxy %q: %v", cidr, err))
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPort removes a port and IPv6 brackets from a forwarded address
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+func directScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	parts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
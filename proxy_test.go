@@ -0,0 +1,145 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyHeadersMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        ProxyConfig
+		remoteAddr string
+		headers    map[string]string
+		wantIP     string
+		wantScheme string
+		wantHost   string
+	}{
+		{
+			name:       "untrusted peer ignores headers",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "198.51.100.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Forwarded-Proto": "https"},
+			wantIP:     "198.51.100.7",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "trusted peer uses X-Forwarded-For",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "https",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "client prepended X-Forwarded-For is skipped",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "spoofed Forwarded is ignored in X-Forwarded mode",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "Forwarded": "for=1.2.3.4;host=evil.example;proto=https"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "spoofed Forwarded alone is ignored in X-Forwarded mode",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"Forwarded": "for=1.2.3.4"},
+			wantIP:     "10.0.0.5",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "spoofed X-Forwarded-Host is ignored by default",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Host": "evil.example"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "X-Forwarded-Host honored with TrustHost",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}, TrustHost: true},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Host": "public.example"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "public.example",
+		},
+		{
+			name:       "spoofed X-Forwarded-For is ignored in Forwarded mode",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}, Header: ProxyHeaderForwarded},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"Forwarded": "for=203.0.113.9;proto=https", "X-Forwarded-For": "1.2.3.4"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "https",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "Forwarded host honored with TrustHost",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}, Header: ProxyHeaderForwarded, TrustHost: true},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"Forwarded": `for="[2001:db8::1]:4711";host=public.example`},
+			wantIP:     "2001:db8::1",
+			wantScheme: "http",
+			wantHost:   "public.example",
+		},
+		{
+			name:       "chain of trusted proxies",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9, 10.0.0.7"},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			cfg:        ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, not-an-ip"},
+			wantIP:     "10.0.0.5",
+			wantScheme: "http",
+			wantHost:   "app.example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotIP, gotScheme, gotHost string
+			h := ProxyHeadersMiddleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotIP, gotScheme, gotHost = ClientIP(r), RequestScheme(r), RequestHost(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotIP != tt.wantIP {
+				t.Errorf("ClientIP = %q, want %q", gotIP, tt.wantIP)
+			}
+			if gotScheme != tt.wantScheme {
+				t.Errorf("RequestScheme = %q, want %q", gotScheme, tt.wantScheme)
+			}
+			if gotHost != tt.wantHost {
+				t.Errorf("RequestHost = %q, want %q", gotHost, tt.wantHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package gex
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// RateLimitAlgorithm selects how requests are counted
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens per Window and allows bursts up to Burst
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows Limit requests in any Window, weighting the previous window
+	SlidingWindow
+)
+
+// RateLimit describes one limit
+type RateLimit struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int           // requests allowed per Window
+	Window    time.Duration // Window the limit applies to
+	Burst     int           // TokenBucket capacity; zero uses Limit
+}
+
+// RateLimitResult is the outcome of taking one request from a limit
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the limit is fully available again
+	RetryAfter time.Duration // until the next request would be allowed, when denied
+}
+
+// RateLimitStore holds limiter state. Implementations must apply Take atomically
+// per key so several app instances can share a store, e.g. Redis.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc picks the key a request is counted under.
+// Returning "" exempts the request from the limit.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP counts requests per client IP, as resolved by ClientIP
+func KeyByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// KeyBySession counts requests per session, falling back to the client IP when
+// SessionMiddleware has not resolved one or has just minted it. A client
+// without a token gets a fresh session on every request, so its session key
+// can't be counted.
+func KeyBySession(r *http.Request) string {
+	if result, ok := sessionprovider.FromContext(r.Context()); ok && !result.DidMintToken {
+		if key, ok := result.Session.Get("key"); ok {
+			if sessionKey, ok := key.(string); ok && sessionKey != "" {
+				return "session:" + SessionKeyHash(sessionKey)
+			}
+		}
+	}
+	return KeyByIP(r)
+}
+
+// KeyByUser counts requests per logged in user, falling back to the client IP
+// for anonymous requests and sessions minted by this request
+func KeyByUser(r *http.Request) string {
+	if result, ok := sessionprovider.FromContext(r.Context()); ok && result.DidMintToken {
+		return KeyByIP(r)
+	}
+	if userID := UserID(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	return KeyByIP(r)
+}
+
+// RateLimitConfig configures RateLimitMiddleware
+type RateLimitConfig struct {
+	RateLimit
+
+	// Name prefixes keys so limiters can share a store. Optional.
+	Name string
+
+	// Key picks the counting key. Nil uses KeyByIP.
+	Key RateLimitKeyFunc
+
+	// Store holds the limiter state. Nil uses a new MemoryRateLimitStore.
+	Store RateLimitStore
+}
+
+// RateLimitMiddleware limits requests per key, answering 429 with Retry-After once the
+// limit is used up. Every response carries RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers. If the store fails the request is let through and logged.
+func RateLimitMiddleware(cfg RateLimitConfig) Middleware {
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		panic("gex: rate limit needs a positive Limit and Window")
+	}
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	policy := fmt.Sprintf("%d;w=%d", cfg.Limit, int(math.Ceil(cfg.Window.Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.Name != "" {
+				key = cfg.Name + ":" + key
+			}
+
+			result, err := cfg.Store.Take(r.Context(), key, cfg.RateLimit, time.Now())
+			if err != nil {
+				slog.WarnContext(r.Context(), "rate limit store failed, allowing request",
+					slog.String("error", err.Error()),
+					slog.String("request_id", RequestID(r.Context())),
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+				WriteError(w, r, ErrTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore.
+// Keys idle long enough to be fully replenished are evicted.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+type rateLimitEntry struct {
+	// TokenBucket
+	tokens float64
+	last   time.Time
+
+	// SlidingWindow
+	windowStart time.Time
+	curr, prev  int
+
+	expires time.Time
+}
+
+// rateLimitSweepInterval is how often idle keys are looked for
+const rateLimitSweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[string]*rateLimitEntry)}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		s.sweep(now)
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateLimitEntry{}
+		s.entries[key] = e
+	}
+
+	switch limit.Algorithm {
+	case SlidingWindow:
+		return e.takeSlidingWindow(limit, now), nil
+	default:
+		return e.takeTokenBucket(limit, now), nil
+	}
+}
+
+// Len returns the number of keys currently tracked
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (e *rateLimitEntry) takeTokenBucket(limit RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.Burst)
+	if capacity <= 0 {
+		capacity = float64(limit.Limit)
+	}
+	rate := float64(limit.Limit) / limit.Window.Seconds() // tokens per second
+
+	// Refill since the last request
+	if e.last.IsZero() {
+		e.tokens = capacity
+	} else if elapsed := now.Sub(e.last).Seconds(); elapsed > 0 {
+		e.tokens = math.Min(capacity, e.tokens+elapsed*rate)
+	}
+	e.last = now
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - e.tokens) / rate)
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = seconds((capacity - e.tokens) / rate)
+	e.expires = now.Add(result.Reset)
+
+	return result
+}
+
+func (e *rateLimitEntry) takeSlidingWindow(limit RateLimit, now time.Time) RateLimitResult {
+	// Move the window forward
+	start := now.Truncate(limit.Window)
+	switch {
+	case e.windowStart.IsZero() || start.Sub(e.windowStart) >= 2*limit.Window:
+		e.prev, e.curr = 0, 0
+	case start.After(e.windowStart):
+		e.prev, e.curr = e.curr, 0
+	}
+	e.windowStart = start
+
+	// Weight the previous window by how much of it still overlaps
+	elapsed := now.Sub(start)
+	weight := 1 - elapsed.Seconds()/limit.Window.Seconds()
+	count := float64(e.prev)*weight + float64(e.curr)
+
+	result := RateLimitResult{Limit: limit.Limit}
+	if count+1 <= float64(limit.Limit) {
+		e.curr++
+		count++
+		result.Allowed = true
+	} else if e.curr >= limit.Limit || e.prev == 0 {
+		result.RetryAfter = limit.Window - elapsed
+	} else {
+		// Wait until enough of the previous window has slid out
+		needed := (count + 1 - float64(limit.Limit)) / float64(e.prev)
+		result.RetryAfter = seconds(needed * limit.Window.Seconds())
+	}
+	result.Remaining = max(0, limit.Limit-int(math.Ceil(count)))
+	result.Reset = 2*limit.Window - elapsed
+	e.expires = now.Add(result.Reset)
+
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
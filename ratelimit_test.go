@@ -0,0 +1,193 @@
+package gex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// rateLimitEpoch is aligned to every window used below
+var rateLimitEpoch = time.Unix(1000, 0)
+
+type takeStep struct {
+	at            time.Duration // since rateLimitEpoch
+	wantAllowed   bool
+	wantRemaining int
+	wantRetry     time.Duration // checked when denied
+}
+
+func runTakeSteps(t *testing.T, limit RateLimit, steps []takeStep) {
+	t.Helper()
+
+	store := NewMemoryRateLimitStore()
+	for i, step := range steps {
+		got, err := store.Take(context.Background(), "k", limit, rateLimitEpoch.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: Take: %v", i, err)
+		}
+		if got.Allowed != step.wantAllowed {
+			t.Errorf("step %d at %v: Allowed = %v, want %v", i, step.at, got.Allowed, step.wantAllowed)
+		}
+		if got.Remaining != step.wantRemaining {
+			t.Errorf("step %d at %v: Remaining = %d, want %d", i, step.at, got.Remaining, step.wantRemaining)
+		}
+		if !step.wantAllowed && got.RetryAfter != step.wantRetry {
+			t.Errorf("step %d at %v: RetryAfter = %v, want %v", i, step.at, got.RetryAfter, step.wantRetry)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// 10 per 10s refills one token a second, bursts up to 3
+	limit := RateLimit{Algorithm: TokenBucket, Limit: 10, Window: 10 * time.Second, Burst: 3}
+
+	runTakeSteps(t, limit, []takeStep{
+		{at: 0, wantAllowed: true, wantRemaining: 2},
+		{at: 0, wantAllowed: true, wantRemaining: 1},
+		{at: 0, wantAllowed: true, wantRemaining: 0},
+		{at: 0, wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+		{at: 500 * time.Millisecond, wantAllowed: false, wantRemaining: 0, wantRetry: 500 * time.Millisecond},
+		{at: time.Second, wantAllowed: true, wantRemaining: 0},
+		// Idle long enough to refill, but never beyond the burst
+		{at: time.Minute, wantAllowed: true, wantRemaining: 2},
+	})
+}
+
+func TestTokenBucketBurstDefaultsToLimit(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Algorithm: TokenBucket, Limit: 5, Window: time.Minute}
+
+	for i := range 5 {
+		if got, _ := store.Take(context.Background(), "k", limit, rateLimitEpoch); !got.Allowed {
+			t.Fatalf("request %d denied, want allowed", i)
+		}
+	}
+	got, _ := store.Take(context.Background(), "k", limit, rateLimitEpoch)
+	if got.Allowed || got.Limit != 5 || got.RetryAfter != 12*time.Second {
+		t.Errorf("6th request = %+v, want denied with Limit 5 and RetryAfter 12s", got)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limit := RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}
+
+	runTakeSteps(t, limit, []takeStep{
+		{at: time.Second, wantAllowed: true, wantRemaining: 3},
+		{at: time.Second, wantAllowed: true, wantRemaining: 2},
+		{at: time.Second, wantAllowed: true, wantRemaining: 1},
+		{at: time.Second, wantAllowed: true, wantRemaining: 0},
+		// Current window full: wait for it to end
+		{at: time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 9 * time.Second},
+		// Halfway through the next window the previous 4 weigh 2
+		{at: 15 * time.Second, wantAllowed: true, wantRemaining: 1},
+		{at: 15 * time.Second, wantAllowed: true, wantRemaining: 0},
+		// 2 + 2 = 4: a quarter of the previous window must slide out
+		{at: 15 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetry: 2500 * time.Millisecond},
+		{at: 17500 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+		// Two windows later everything is forgotten
+		{at: 40 * time.Second, wantAllowed: true, wantRemaining: 3},
+	})
+}
+
+func TestMemoryRateLimitStoreSweepsIdleKeys(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Algorithm: TokenBucket, Limit: 1, Window: time.Second}
+
+	store.Take(context.Background(), "a", limit, rateLimitEpoch)
+	store.Take(context.Background(), "b", limit, rateLimitEpoch)
+	if n := store.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+
+	store.Take(context.Background(), "c", limit, rateLimitEpoch.Add(2*rateLimitSweepInterval))
+	if n := store.Len(); n != 1 {
+		t.Errorf("Len after sweep = %d, want 1", n)
+	}
+}
+
+func sessionRequest(minted bool, values map[string]any) *http.Request {
+	sess := session.NewInMemorySession()
+	for k, v := range values {
+		sess.Put(k, v)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.9:1234"
+	ctx := sessionprovider.NewContext(r.Context(), &sessionprovider.SessionResult{Session: sess, DidMintToken: minted})
+	return r.WithContext(ctx)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  RateLimitKeyFunc
+		r    *http.Request
+		want string
+	}{
+		{"session", KeyBySession, sessionRequest(false, map[string]any{"key": "abc"}), "session:" + SessionKeyHash("abc")},
+		{"minted session falls back to IP", KeyBySession, sessionRequest(true, map[string]any{"key": "abc"}), "ip:203.0.113.9"},
+		{"no session falls back to IP", KeyBySession, httptest.NewRequest(http.MethodGet, "/", nil), "ip:192.0.2.1"},
+		{"user", KeyByUser, sessionRequest(false, map[string]any{"key": "abc", SessionUserID: "42"}), "user:42"},
+		{"anonymous user falls back to IP", KeyByUser, sessionRequest(false, map[string]any{"key": "abc"}), "ip:203.0.113.9"},
+		{"minted session user falls back to IP", KeyByUser, sessionRequest(true, map[string]any{"key": "abc", SessionUserID: "42"}), "ip:203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key(tt.r); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareCountsAnonymousSessionsByIP(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	h := RateLimitMiddleware(RateLimitConfig{
+		RateLimit: RateLimit{Limit: 2, Window: time.Minute},
+		Key:       KeyBySession,
+		Store:     store,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Every anonymous request arrives with a freshly minted session key
+	var codes []int
+	for i := range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, sessionRequest(true, map[string]any{"key": "minted-" + string(rune('a'+i))}))
+		codes = append(codes, rec.Code)
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want [200 200 429]", codes)
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("store keys = %d, want 1", n)
+	}
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	h := RateLimitMiddleware(RateLimitConfig{
+		RateLimit: RateLimit{Limit: 1, Window: time.Minute},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("RateLimit-Policy"); got != "1;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want %q", got, "1;w=60")
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
@@ -0,0 +1,64 @@
+package gex
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoveryConfig configures RecoveryMiddleware
+type RecoveryConfig struct {
+	// Logger receives the panic and its stack. Nil uses slog.Default().
+	Logger *slog.Logger
+
+	// OnPanic, when set, is called after logging, e.g. to report to an error sink
+	OnPanic func(r *http.Request, recovered any, stack []byte)
+}
+
+// RecoveryMiddleware recovers panics from the handlers it wraps. It logs the stack
+// with the request ID and, if the response has not started, replies with a JSON 500
+// carrying the request ID. http.ErrAbortHandler is re-panicked so net/http can abort.
+func RecoveryMiddleware(cfg RecoveryConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				stack := debug.Stack()
+				if hp, ok := p.(*handlerPanic); ok {
+					p, stack = hp.value, hp.stack
+				}
+				logger := cfg.Logger
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.ErrorContext(r.Context(), "panic recovered",
+					slog.String("panic", fmt.Sprint(p)),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", requestIDOf(r, rw)),
+					slog.String("stack", string(stack)),
+				)
+
+				if cfg.OnPanic != nil {
+					cfg.OnPanic(r, p, stack)
+				}
+
+				if !rw.Written() {
+					WriteError(rw, r, ErrInternal)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
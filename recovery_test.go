@@ -0,0 +1,120 @@
+package gex
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantPanic  bool
+	}{
+		{
+			name:       "no panic",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "panic before writing",
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `"code":"internal_error"`,
+			wantPanic:  true,
+		},
+		{
+			name: "panic after writing keeps the response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("partial"))
+				panic("boom")
+			},
+			wantStatus: http.StatusAccepted,
+			wantBody:   "partial",
+			wantPanic:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			var recovered any
+			var stack []byte
+			h := RequestIDMiddleware()(RecoveryMiddleware(RecoveryConfig{
+				Logger: slog.New(slog.NewJSONHandler(&logs, nil)),
+				OnPanic: func(r *http.Request, p any, s []byte) {
+					recovered, stack = p, s
+				},
+			})(tt.handler))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+			if !tt.wantPanic {
+				if recovered != nil || logs.Len() > 0 {
+					t.Errorf("recovered %v, logged %s", recovered, logs.String())
+				}
+				return
+			}
+
+			if recovered != "boom" || len(stack) == 0 {
+				t.Errorf("OnPanic got %v with a %d byte stack", recovered, len(stack))
+			}
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("log %q: %v", logs.String(), err)
+			}
+			if entry["panic"] != "boom" || entry["request_id"] != "req-1" || entry["stack"] == "" {
+				t.Errorf("log entry = %v", entry)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareBodyCarriesRequestID(t *testing.T) {
+	h := RequestIDMiddleware()(RecoveryMiddleware(RecoveryConfig{
+		Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.RequestID != "req-2" {
+		t.Errorf("request_id = %q, want req-2", body.Error.RequestID)
+	}
+}
+
+func TestRecoveryMiddlewareAbortHandler(t *testing.T) {
+	h := RecoveryMiddleware(RecoveryConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
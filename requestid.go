@@ -0,0 +1,110 @@
+package gex
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/i247app/gex/requestid"
+)
+
+// RequestIDHeader is the header used to accept and echo request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied IDs so they can't bloat logs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request a correlation ID.
+// It uses the incoming X-Request-ID header if it is sane, else the trace ID of
+// a W3C traceparent header, else a freshly generated ID. The ID is stored in the
+// request context and echoed in the X-Request-ID response header.
+func RequestIDMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = ""
+				if tp, ok := parseTraceparent(r.Header.Get("Traceparent")); ok {
+					id = tp.traceID
+				}
+			}
+			if id == "" {
+				id = requestid.Generate()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// RequestID returns the ID assigned to the request carrying ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// WithRequestID returns a copy of ctx carrying id, e.g. to propagate it to background work
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.NewContext(ctx, id)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// traceparent is a parsed W3C Trace Context header
+type traceparent struct {
+	traceID  string
+	parentID string
+	flags    string
+}
+
+// parseTraceparent parses a version 00 traceparent header:
+// "00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>"
+func parseTraceparent(header string) (traceparent, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || parts[0] == "ff" || !isHex(parts[0], 2) {
+		return traceparent{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return traceparent{}, false
+	}
+
+	tp := traceparent{traceID: parts[1], parentID: parts[2], flags: parts[3]}
+	if !isHex(tp.traceID, 32) || !isHex(tp.parentID, 16) || !isHex(tp.flags, 2) {
+		return traceparent{}, false
+	}
+	if strings.Trim(tp.traceID, "0") == "" || strings.Trim(tp.parentID, "0") == "" {
+		return traceparent{}, false
+	}
+	return tp, true
+}
+
+// sampled reports whether the upstream caller recorded this trace
+func (tp traceparent) sampled() bool {
+	flags, _ := strconv.ParseUint(tp.flags, 16, 8)
+	return flags&1 == 1
+}
+
+// isHex reports whether s is n lowercase hex digits
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if id := FromContext(context.Background()); id != "" {
+		t.Errorf("FromContext(empty) = %q, want empty", id)
+	}
+	if id := FromContext(NewContext(context.Background(), "abc")); id != "abc" {
+		t.Errorf("FromContext = %q, want abc", id)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	a, b := Generate(), Generate()
+	if len(a) != 32 || a == b {
+		t.Errorf("Generate = %q, %q, want distinct 32 character IDs", a, b)
+	}
+}
@@ -0,0 +1,97 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{"valid", testTraceparent, true},
+		{"surrounding space", "  " + testTraceparent + " ", true},
+		{"future version with extra fields", "01-" + testTraceID + "-" + testParentID + "-01-extra", true},
+		{"version 00 with extra fields", testTraceparent + "-extra", false},
+		{"invalid version ff", "ff-" + testTraceID + "-" + testParentID + "-01", false},
+		{"uppercase hex", "00-" + strings.ToUpper(testTraceID) + "-" + testParentID + "-01", false},
+		{"short trace id", "00-4bf92f35-" + testParentID + "-01", false},
+		{"zero trace id", "00-" + strings.Repeat("0", 32) + "-" + testParentID + "-01", false},
+		{"zero parent id", "00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01", false},
+		{"bad flags", "00-" + testTraceID + "-" + testParentID + "-x1", false},
+		{"too few fields", "00-" + testTraceID, false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, ok := parseTraceparent(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && (tp.traceID != testTraceID || tp.parentID != testParentID || tp.flags != "01") {
+				t.Errorf("parsed %+v", tp)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string // empty means a generated ID
+	}{
+		{"incoming ID", map[string]string{"X-Request-ID": "abc-123"}, "abc-123"},
+		{"traceparent", map[string]string{"Traceparent": testTraceparent}, testTraceID},
+		{"incoming ID wins over traceparent", map[string]string{"X-Request-ID": "abc-123", "Traceparent": testTraceparent}, "abc-123"},
+		{"unsafe ID falls back to traceparent", map[string]string{"X-Request-ID": "a b\n", "Traceparent": testTraceparent}, testTraceID},
+		{"overlong ID is replaced", map[string]string{"X-Request-ID": strings.Repeat("a", maxRequestIDLength+1)}, ""},
+		{"none", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			h := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(RequestIDHeader)
+			if echoed != ctxID {
+				t.Errorf("echoed %q, context has %q", echoed, ctxID)
+			}
+			if tt.want != "" && ctxID != tt.want {
+				t.Errorf("ID = %q, want %q", ctxID, tt.want)
+			}
+			if tt.want == "" && !isHex(ctxID, 32) {
+				t.Errorf("generated ID = %q, want 32 hex digits", ctxID)
+			}
+		})
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if id := RequestID(req.Context()); id != "" {
+		t.Errorf("RequestID without middleware = %q, want empty", id)
+	}
+	if id := RequestID(WithRequestID(req.Context(), "job-1")); id != "job-1" {
+		t.Errorf("RequestID = %q, want job-1", id)
+	}
+}
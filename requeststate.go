@@ -0,0 +1,81 @@
+package gex
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// requestState is per-request data shared between gex middleware and the mux.
+// Middleware wrap the request with new contexts, so inner layers can't hand
+// values back out through the request itself; they write here instead.
+type requestState struct {
+	mu      sync.Mutex
+	pattern string
+	attrs   []slog.Attr
+}
+
+type requestStateKey struct{}
+
+// withRequestState returns the request's state, attaching a new one if needed
+func withRequestState(r *http.Request) (*requestState, *http.Request) {
+	if st := getRequestState(r.Context()); st != nil {
+		return st, r
+	}
+	st := &requestState{}
+	return st, r.WithContext(context.WithValue(r.Context(), requestStateKey{}, st))
+}
+
+func getRequestState(ctx context.Context) *requestState {
+	st, _ := ctx.Value(requestStateKey{}).(*requestState)
+	return st
+}
+
+func (st *requestState) setPattern(pattern string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.pattern = pattern
+}
+
+func (st *requestState) routePattern() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.pattern
+}
+
+// RoutePattern returns the mux pattern that matched r, e.g. "GET /users/{id}".
+// Unlike r.Pattern it is also available to middleware wrapping the whole app,
+// once the request has been routed.
+func RoutePattern(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	if st := getRequestState(r.Context()); st != nil {
+		return st.routePattern()
+	}
+	return ""
+}
+
+// AddLogAttrs attaches attributes to the access log line of the request carrying ctx.
+// It is a no-op when access logging is not enabled.
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	st := getRequestState(ctx)
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.attrs = append(st.attrs, attrs...)
+}
+
+func (st *requestState) logAttrs() []slog.Attr {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return append([]slog.Attr{}, st.attrs...)
+}
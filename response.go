@@ -0,0 +1,223 @@
+package gex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HTTPError is an error carrying the HTTP status, a stable machine readable code
+// and a client safe message. Err, if set, is the underlying cause; it is
+// logged but never sent to the client.
+type HTTPError struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+	Err     error
+}
+
+// NewHTTPError creates an HTTPError
+func NewHTTPError(status int, code, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %s: %v", e.Status, e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches another HTTPError with the same status and code,
+// so errors.Is(err, ErrNotFound) works on copies made by WithDetails and Wrap
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Status == e.Status && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e carrying details, e.g. per-field validation errors
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Wrap returns a copy of e with err as its cause
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// Common errors
+var (
+	ErrBadRequest       = NewHTTPError(http.StatusBadRequest, "bad_request", "bad request")
+	ErrUnauthorized     = NewHTTPError(http.StatusUnauthorized, "unauthorized", "unauthorized")
+	ErrForbidden        = NewHTTPError(http.StatusForbidden, "forbidden", "forbidden")
+	ErrNotFound         = NewHTTPError(http.StatusNotFound, "not_found", "not found")
+	ErrMethodNotAllowed = NewHTTPError(http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	ErrConflict         = NewHTTPError(http.StatusConflict, "conflict", "conflict")
+	ErrRequestTooLarge  = NewHTTPError(http.StatusRequestEntityTooLarge, "request_too_large", "request body too large")
+	ErrTooManyRequests  = NewHTTPError(http.StatusTooManyRequests, "too_many_requests", "too many requests")
+	ErrInternal         = NewHTTPError(http.StatusInternalServerError, "internal_error", "internal server error")
+	ErrUnavailable      = NewHTTPError(http.StatusServiceUnavailable, "unavailable", "service unavailable")
+	ErrGatewayTimeout   = NewHTTPError(http.StatusGatewayTimeout, "timeout", "request timed out")
+)
+
+type errorMapping struct {
+	target error
+	status int
+	code   string
+}
+
+var (
+	errorMappingsMu sync.RWMutex
+	errorMappings   []errorMapping
+)
+
+// RegisterError maps errors matching target (by errors.Is) to an HTTP status and code.
+// The client gets target's message, so wrapping context added to the error stays
+// in the logs. Later registrations take precedence.
+func RegisterError(target error, status int, code string) {
+	errorMappingsMu.Lock()
+	defer errorMappingsMu.Unlock()
+
+	errorMappings = append(errorMappings, errorMapping{target: target, status: status, code: code})
+}
+
+// AsHTTPError converts any error to an HTTPError: an HTTPError in the chain is used
+// as is, then registered mappings are tried, then a few standard library errors.
+// Anything else becomes ErrInternal wrapping err.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	errorMappingsMu.RLock()
+	for i := len(errorMappings) - 1; i >= 0; i-- {
+		m := errorMappings[i]
+		if errors.Is(err, m.target) {
+			errorMappingsMu.RUnlock()
+			return &HTTPError{Status: m.status, Code: m.code, Message: m.target.Error(), Err: err}
+		}
+	}
+	errorMappingsMu.RUnlock()
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return ErrRequestTooLarge.Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrGatewayTimeout.Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return ErrUnavailable.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// WriteJSON writes v as a JSON body with the given status
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteError maps err with AsHTTPError and writes it as
+//
+//	{"error": {"code": ..., "message": ..., "details": ..., "request_id": ...}}
+//
+// or as RFC 7807 problem+json when the client asks for it in its Accept header.
+// Server errors are logged with their cause.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	if acceptsProblem(r) {
+		WriteProblem(w, r, err)
+		return
+	}
+
+	httpErr := AsHTTPError(err)
+	logServerError(r, httpErr)
+	WriteJSON(w, httpErr.Status, errorBody{Error: errorDetail{
+		Code:      httpErr.Code,
+		Message:   httpErr.Message,
+		Details:   httpErr.Details,
+		RequestID: requestIDOf(r, w),
+	}})
+}
+
+type problemBody struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteProblem maps err with AsHTTPError and writes it as RFC 7807 application/problem+json
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	httpErr := AsHTTPError(err)
+	logServerError(r, httpErr)
+
+	body := problemBody{
+		Type:      "about:blank",
+		Title:     http.StatusText(httpErr.Status),
+		Status:    httpErr.Status,
+		Detail:    httpErr.Message,
+		Instance:  r.URL.Path,
+		Code:      httpErr.Code,
+		Details:   httpErr.Details,
+		RequestID: requestIDOf(r, w),
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpErr.Status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func acceptsProblem(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "application/problem+json") {
+			return true
+		}
+	}
+	return false
+}
+
+func logServerError(r *http.Request, httpErr *HTTPError) {
+	if httpErr.Status < 500 || httpErr.Err == nil {
+		return
+	}
+	slog.ErrorContext(r.Context(), "request failed",
+		slog.Int("status", httpErr.Status),
+		slog.String("error", httpErr.Err.Error()),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("request_id", RequestID(r.Context())),
+	)
+}
@@ -0,0 +1,65 @@
+package gex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var errOutOfStock = errors.New("out of stock")
+
+func TestAsHTTPErrorRegistered(t *testing.T) {
+	RegisterError(errOutOfStock, http.StatusConflict, "out_of_stock")
+
+	err := fmt.Errorf("reserve sku 42 for user 7 in db shard 3: %w", errOutOfStock)
+	httpErr := AsHTTPError(err)
+
+	if httpErr.Status != http.StatusConflict || httpErr.Code != "out_of_stock" {
+		t.Errorf("got %d %s, want 409 out_of_stock", httpErr.Status, httpErr.Code)
+	}
+	if httpErr.Message != "out of stock" {
+		t.Errorf("Message = %q, want the registered error's message", httpErr.Message)
+	}
+	if httpErr.Err != err {
+		t.Errorf("Err = %v, want the original error", httpErr.Err)
+	}
+
+	rec := httptest.NewRecorder()
+	WriteError(rec, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Code != "out_of_stock" || body.Error.Message != "out of stock" {
+		t.Errorf("body = %s, want only the registered message", rec.Body)
+	}
+}
+
+func TestAsHTTPErrorFallbacks(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *HTTPError
+	}{
+		{ErrNotFound.WithDetails("x"), ErrNotFound},
+		{fmt.Errorf("wrapped: %w", ErrForbidden), ErrForbidden},
+		{&http.MaxBytesError{Limit: 1}, ErrRequestTooLarge},
+		{errors.New("secret internals"), ErrInternal},
+	}
+	for _, tt := range tests {
+		got := AsHTTPError(tt.err)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("AsHTTPError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+		if got.Message == "secret internals" {
+			t.Errorf("AsHTTPError(%v) exposes the error text", tt.err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package gex
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	// Pattern is the full ServeMux pattern, e.g. "GET /users/{id}"
+	Pattern string `json:"pattern"`
+	// Method is empty for routes matching every method
+	Method string `json:"method,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Path   string `json:"path"`
+
+	// Middleware names the route and group middleware, outermost first.
+	// App-wide middleware from RegisterMiddleware applies to every route and is not listed.
+	Middleware []string `json:"middleware,omitempty"`
+
+	// Handler is the handler function name and Source where it is defined
+	Handler string `json:"handler"`
+	Source  string `json:"source,omitempty"`
+
+	// Summary and Tags are the route's annotations, see Route
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+type routeEntry struct {
+	pattern    string
+	handler    http.Handler
+	middleware []middlewareInfo
+	doc        routeDoc
+}
+
+// middlewareInfo describes a route middleware, see describeMiddleware
+type middlewareInfo struct {
+	name  string
+	guard bool
+}
+
+// NamedMiddleware names mw in the route table, e.g. "auth.RequireScope".
+// Unnamed middleware are listed by the function that built them, which is
+// the same for every middleware returned by a shared helper.
+func NamedMiddleware(name string, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		return &namedHandler{name: name, Handler: mw(next)}
+	}
+}
+
+// GuardMiddleware is NamedMiddleware for authorization guards: routes using
+// mw are documented as requiring the bearer token, as with Route.BearerAuth
+func GuardMiddleware(name string, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		return &namedHandler{name: name, guard: true, Handler: mw(next)}
+	}
+}
+
+// namedHandler is a handler built by a named middleware
+type namedHandler struct {
+	http.Handler
+	name  string
+	guard bool
+}
+
+// describeMiddleware describes mw from the handler h it built
+func describeMiddleware(mw Middleware, h http.Handler) middlewareInfo {
+	if nh, ok := h.(*namedHandler); ok {
+		return middlewareInfo{name: nh.name, guard: nh.guard}
+	}
+	name, _ := funcName(mw)
+	return middlewareInfo{name: name}
+}
+
+// routeDoc holds the annotations set through Route
+type routeDoc struct {
+	summary     string
+	description string
+	tags        []string
+	bearerAuth  bool
+	request     reflect.Type
+	response    reflect.Type
+	hidden      bool
+}
+
+// Route is a registered route. Its methods annotate the route for the
+// OpenAPI document and return the route so calls can be chained:
+//
+//	app.AddRoute("POST /users", gex.Handle(svc.CreateUser)).
+//		Summary("Create a user").
+//		Tags("users").
+//		Request(CreateUserRequest{}).
+//		Response(User{})
+type Route struct {
+	mux   *gexMux
+	entry *routeEntry
+}
+
+func (rt *Route) update(fn func(doc *routeDoc)) *Route {
+	rt.mux.routesMu.Lock()
+	defer rt.mux.routesMu.Unlock()
+
+	fn(&rt.entry.doc)
+	return rt
+}
+
+// Summary sets a short summary of what the route does
+func (rt *Route) Summary(summary string) *Route {
+	return rt.update(func(doc *routeDoc) { doc.summary = summary })
+}
+
+// Description sets a longer description of the route, CommonMark allowed
+func (rt *Route) Description(description string) *Route {
+	return rt.update(func(doc *routeDoc) { doc.description = description })
+}
+
+// Tags adds tags grouping the route in the OpenAPI document
+func (rt *Route) Tags(tags ...string) *Route {
+	return rt.update(func(doc *routeDoc) { doc.tags = append(doc.tags, tags...) })
+}
+
+// BearerAuth marks the route as requiring the JWT issued by the session
+// provider in the Authorization header. Routes using a guard, such as
+// RequireRole or one built with GuardMiddleware, are marked automatically.
+func (rt *Route) BearerAuth() *Route {
+	return rt.update(func(doc *routeDoc) { doc.bearerAuth = true })
+}
+
+// Request declares the type the route binds its request into, as with Handle.
+// v is a value or pointer of that type, e.g. CreateUserRequest{}.
+func (rt *Route) Request(v any) *Route {
+	return rt.update(func(doc *routeDoc) { doc.request = reflect.TypeOf(v) })
+}
+
+// Response declares the type the route answers with as JSON
+func (rt *Route) Response(v any) *Route {
+	return rt.update(func(doc *routeDoc) { doc.response = reflect.TypeOf(v) })
+}
+
+// Hidden leaves the route out of the OpenAPI document
+func (rt *Route) Hidden() *Route {
+	return rt.update(func(doc *routeDoc) { doc.hidden = true })
+}
+
+// snapshotRoutes copies the registered routes in registration order
+func (m *gexMux) snapshotRoutes() []routeEntry {
+	m.routesMu.Lock()
+	defer m.routesMu.Unlock()
+
+	entries := make([]routeEntry, len(m.routes))
+	for i, e := range m.routes {
+		entries[i] = *e
+		entries[i].middleware = slices.Clone(e.middleware)
+		entries[i].doc.tags = slices.Clone(e.doc.tags)
+	}
+	return entries
+}
+
+// Routes returns the registered routes sorted by path, then method
+func (a *App) Routes() []RouteInfo {
+	entries := a.mux.snapshotRoutes()
+
+	routes := make([]RouteInfo, 0, len(entries))
+	for _, e := range entries {
+		method, host, path := splitPattern(e.pattern)
+		info := RouteInfo{
+			Pattern: e.pattern,
+			Method:  method,
+			Host:    host,
+			Path:    path,
+			Summary: e.doc.summary,
+			Tags:    e.doc.tags,
+		}
+		for _, mw := range e.middleware {
+			info.Middleware = append(info.Middleware, mw.name)
+		}
+		info.Handler, info.Source = funcName(e.handler)
+		routes = append(routes, info)
+	}
+
+	slices.SortStableFunc(routes, func(x, y RouteInfo) int {
+		return cmp.Or(cmp.Compare(x.Path, y.Path), cmp.Compare(x.Host, y.Host), cmp.Compare(x.Method, y.Method))
+	})
+	return routes
+}
+
+// EnableRoutes serves the route table at path: plain text by default,
+// JSON when the client accepts application/json
+func (a *App) EnableRoutes(path string) {
+	a.AddRoute("GET "+path, a.writeRoutes).Hidden()
+}
+
+// EnableRoutes serves the app route table on the admin listener, see App.EnableRoutes
+func (ad *Admin) EnableRoutes(path string) {
+	ad.AddRoute("GET "+path, ad.app.writeRoutes)
+}
+
+func (a *App) writeRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := a.Routes()
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		WriteJSON(w, http.StatusOK, routes)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER\tMIDDLEWARE")
+	for _, route := range routes {
+		method := route.Method
+		if method == "" {
+			method = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", method, route.Host+route.Path, route.Handler, strings.Join(route.Middleware, ", "))
+	}
+	tw.Flush()
+}
+
+// funcName returns the short name of the function behind fn, e.g. "gex.RequestIDMiddleware",
+// and the file:line it is defined at. Closure suffixes are dropped so
+// middleware report the constructor that built them.
+func funcName(fn any) (name, source string) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", fn), ""
+	}
+
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return "unknown", ""
+	}
+	if file, line := f.FileLine(f.Entry()); !strings.HasPrefix(file, "<") {
+		source = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	name = f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	for {
+		i := strings.LastIndex(name, ".func")
+		if i < 0 || strings.Trim(name[i+len(".func"):], "0123456789.") != "" {
+			break
+		}
+		name = name[:i]
+	}
+	return name, source
+}
@@ -0,0 +1,58 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func listUsers(w http.ResponseWriter, r *http.Request) {}
+
+func TestAppRoutes(t *testing.T) {
+	app := NewApp(HostConfig{}, nil)
+	app.AddRoute("POST /users", listUsers, RequestIDMiddleware()).Summary("Create").Tags("users")
+	app.Group("/admin", RequireRole("admin")).AddRoute("GET /stats", listUsers, RequireSecure())
+	app.AddRoute("GET /users", listUsers, NamedMiddleware("app.audit", traceMiddleware("audit")))
+
+	routes := app.Routes()
+	var patterns []string
+	for _, route := range routes {
+		patterns = append(patterns, route.Pattern)
+	}
+	if want := []string{"GET /admin/stats", "GET /users", "POST /users"}; !slices.Equal(patterns, want) {
+		t.Fatalf("patterns = %v, want %v", patterns, want)
+	}
+
+	tests := []struct {
+		route RouteInfo
+		want  []string
+	}{
+		{routes[0], []string{"gex.RequireRole", "gex.RequireSecure"}},
+		{routes[1], []string{"app.audit"}},
+		{routes[2], []string{"gex.RequestIDMiddleware"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.route.Middleware, tt.want) {
+			t.Errorf("%s middleware = %v, want %v", tt.route.Pattern, tt.route.Middleware, tt.want)
+		}
+	}
+
+	post := routes[2]
+	if post.Handler != "gex.listUsers" || post.Source == "" {
+		t.Errorf("handler = %q at %q, want gex.listUsers with a source", post.Handler, post.Source)
+	}
+	if post.Method != http.MethodPost || post.Path != "/users" || post.Summary != "Create" || !slices.Equal(post.Tags, []string{"users"}) {
+		t.Errorf("POST route = %+v", post)
+	}
+}
+
+func TestNamedMiddlewareServes(t *testing.T) {
+	h := NewChain(NamedMiddleware("outer", traceMiddleware("a")), traceMiddleware("b")).ThenFunc(listUsers)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Values("X-Trace"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("X-Trace = %v, want [a b]", got)
+	}
+}
@@ -2,13 +2,27 @@ package gex
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+)
 
-	"github.com/rs/cors"
+// Default server limits, used when the matching HostConfig field is zero
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20  // 1 MiB
+	DefaultMaxBodyBytes      = 10 << 20 // 10 MiB
 )
 
 type HostConfig struct {
@@ -16,110 +30,346 @@ type HostConfig struct {
 	ServerPort    string
 	HttpsCertFile string
 	HttpsKeyFile  string
+
+	// Address, when set, replaces ServerHost and ServerPort. Besides "host:port"
+	// it accepts "unix:/path/to.sock" and "systemd" or "systemd:<name>" for a
+	// socket passed by systemd socket activation (LISTEN_FDS).
+	Address string
+
+	// SocketMode is the permission of a Unix socket created for Address.
+	// Zero uses DefaultSocketMode.
+	SocketMode fs.FileMode
+
+	// Server timeouts and limits. Zero uses the matching Default* value,
+	// a negative value disables the limit.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// MaxBodyBytes caps the size of every request body.
+	// Zero uses DefaultMaxBodyBytes, a negative value disables the cap.
+	MaxBodyBytes int64
+
+	// ShutdownTimeout bounds how long in-flight requests may drain.
+	// ShutdownHookTimeout is the deadline given to each shutdown hook.
+	// Zero uses the matching Default* value, a negative value waits indefinitely.
+	ShutdownTimeout     time.Duration
+	ShutdownHookTimeout time.Duration
+
+	// ShutdownDelay is how long the app keeps serving after reporting not ready,
+	// so load balancers can stop routing to it before draining starts
+	ShutdownDelay time.Duration
+
+	// Routing configures the not found, method not allowed, trailing slash
+	// and OPTIONS handling of the app routes
+	Routing RoutingConfig
+
+	// CORS, when set, is applied by NewApp in front of all middleware
+	CORS *CORSConfig
+
+	// ConfigureTLS, when set, is called with the TLS config before serving, e.g. to
+	// raise MinVersion or plug in an ACME GetCertificate. Setting it enables TLS
+	// even without certificate files.
+	ConfigureTLS func(cfg *tls.Config)
+
+	// CertReloadInterval is how often HttpsCertFile and HttpsKeyFile are checked
+	// for changes. Zero uses DefaultCertReloadInterval, a negative value disables
+	// polling; SIGHUP always triggers a reload.
+	CertReloadInterval time.Duration
+
+	// ClientCAFile enables mutual TLS: client certificates are verified against
+	// these CAs. ClientAuth defaults to tls.RequireAndVerifyClientCert.
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+
+	// RedirectPort, when set with TLS, opens a plain HTTP listener on ServerHost
+	// that redirects to HTTPS. ACMEChallengeHandler, if set, answers
+	// /.well-known/acme-challenge/ on it instead, e.g. for certificate issuance.
+	RedirectPort         string
+	ACMEChallengeHandler http.Handler
+
+	// AdminPort, when set, opens an internal listener serving the App.Admin
+	// routes, e.g. metrics, pprof and health. AdminHost defaults to DefaultAdminHost.
+	AdminHost string
+	AdminPort string
+
+	// GracefulUpgrade makes Start re-exec the binary on SIGUSR2, handing the
+	// listeners to the new process, see App.Upgrade. UpgradeTimeout bounds the
+	// wait for the new process; zero uses DefaultUpgradeTimeout.
+	GracefulUpgrade bool
+	UpgradeTimeout  time.Duration
+
+	// TrustedProxies lists the CIDRs or IPs of proxies, e.g. load balancers, whose
+	// forwarding headers are believed when resolving ClientIP. ProxyHeader and
+	// TrustForwardedHost say which headers they set, see ProxyConfig.
+	TrustedProxies     []string
+	ProxyHeader        ProxyHeader
+	TrustForwardedHost bool
 }
 
 type App struct {
 	HostConfig HostConfig
 
-	mux        *gexMux
-	server     *http.Server
-	onShutdown []func()
+	mux          *gexMux
+	server       *http.Server
+	middleware   Chain
+	cors         Middleware
+	proxyHeaders Middleware
+	metrics      MetricsRecorder
+	handler      http.Handler
+	health       healthChecks
+	certs        *certReloader
+	admin        *Admin
+
+	listener   net.Listener
+	aux        []*auxServer
+	listenerMu sync.Mutex
+	started    chan struct{}
+	startOnce  sync.Once
+
+	ready         atomic.Bool
+	upgrading     atomic.Bool
+	shutdownHooks [shutdownPhaseCount][]ShutdownHook
+	shutdownOnce  sync.Once
+	shutdownErr   error
 }
 
+// Middleware wraps a handler. It may return any http.Handler.
 type Middleware func(http.Handler) http.Handler
 
+// NewApp creates an app. defaultRoute, if not nil, answers requests matching
+// no route unless HostConfig.Routing.NotFound is set.
 func NewApp(hostConfig HostConfig, defaultRoute http.HandlerFunc) *App {
-	// Create the mux
-	mux := &gexMux{
-		mux:            http.NewServeMux(),
-		defaultHandler: defaultRoute,
+	app := &App{
+		HostConfig: hostConfig,
+		mux:        newGexMux(hostConfig.Routing, defaultRoute),
+		started:    make(chan struct{}),
+	}
+	app.admin = &Admin{app: app, mux: http.NewServeMux()}
+	if len(hostConfig.TrustedProxies) > 0 {
+		app.proxyHeaders = ProxyHeadersMiddleware(ProxyConfig{
+			TrustedProxies: hostConfig.TrustedProxies,
+			Header:         hostConfig.ProxyHeader,
+			TrustHost:      hostConfig.TrustForwardedHost,
+		})
 	}
 
 	// Create the server
-	address := fmt.Sprintf("%s:%s", hostConfig.ServerHost, hostConfig.ServerPort)
-	server := &http.Server{
-		Addr:    address,
-		Handler: mux,
+	address := hostConfig.Address
+	if address == "" {
+		address = fmt.Sprintf("%s:%s", hostConfig.ServerHost, hostConfig.ServerPort)
 	}
+	app.server = app.newServer(address, app)
+	app.buildHandler()
 
-	return &App{
-		HostConfig: hostConfig,
-		mux:        mux,
-		server:     server,
+	if hostConfig.CORS != nil {
+		app.SetupServerCORS(*hostConfig.CORS)
 	}
+
+	return app
 }
 
-func (a *App) AddRoute(path string, handler http.HandlerFunc, middleware ...Middleware) {
-	a.mux.addRoute(path, handler, middleware...)
+// newServer creates an http.Server with the configured timeouts and limits
+func (a *App) newServer(addr string, handler http.Handler) *http.Server {
+	hc := a.HostConfig
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: limit(hc.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		ReadTimeout:       limit(hc.ReadTimeout, DefaultReadTimeout),
+		WriteTimeout:      limit(hc.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       limit(hc.IdleTimeout, DefaultIdleTimeout),
+		MaxHeaderBytes:    limit(hc.MaxHeaderBytes, DefaultMaxHeaderBytes),
+	}
+}
+
+// ServeHTTP runs the request through the app middleware and routes
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
 }
 
+// AddRoute registers a route. The middleware is applied outermost first,
+// inside any middleware registered with RegisterMiddleware.
+// The returned Route can be annotated for the OpenAPI document.
+func (a *App) AddRoute(path string, handler http.HandlerFunc, middleware ...Middleware) *Route {
+	return a.mux.addRoute(path, handler, NewChain(middleware...))
+}
+
+// RegisterMiddleware adds app-wide middleware that runs for every request.
+// Middleware registered first is the outermost.
 func (a *App) RegisterMiddleware(middleware Middleware) {
-	a.server.Handler = middleware(a.server.Handler)
+	a.middleware = a.middleware.Append(middleware)
+	a.buildHandler()
 }
 
-func (a *App) SetupServerCORS() {
-	a.server.Handler = cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{
-			http.MethodHead,
-			http.MethodGet,
-			http.MethodPost,
-			http.MethodPut,
-			http.MethodPatch,
-			http.MethodDelete,
-		},
-		ExposedHeaders:   []string{"*"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: false,
-	}).Handler(a.server.Handler)
+// SetupServerCORS installs the CORS policy in front of every other middleware.
+// With no argument it uses HostConfig.CORS, falling back to DefaultCORSConfig.
+// Calling it again replaces the previous policy.
+func (a *App) SetupServerCORS(cfg ...CORSConfig) {
+	policy := DefaultCORSConfig()
+	if len(cfg) > 0 {
+		policy = cfg[0]
+	} else if a.HostConfig.CORS != nil {
+		policy = *a.HostConfig.CORS
+	}
+
+	a.cors = CORSMiddleware(policy)
+	a.buildHandler()
 }
 
-func (a *App) OnShutdown(cleanupFunc func()) {
-	a.onShutdown = append(a.onShutdown, cleanupFunc)
+func (a *App) buildHandler() {
+	var chain Chain
+	if a.HostConfig.ClientCAFile != "" || a.HostConfig.ConfigureTLS != nil {
+		chain = chain.Append(clientIdentityMiddleware)
+	}
+	if a.proxyHeaders != nil {
+		chain = chain.Append(a.proxyHeaders)
+	}
+	if a.metrics != nil {
+		chain = chain.Append(metricsMiddleware(a.metrics))
+	}
+	if a.cors != nil {
+		chain = chain.Append(a.cors)
+	}
+	if maxBody := limit(a.HostConfig.MaxBodyBytes, DefaultMaxBodyBytes); maxBody > 0 {
+		chain = chain.Append(MaxBytesMiddleware(maxBody))
+	}
+	a.handler = chain.Extend(a.middleware).Then(a.mux)
 }
 
-/**
- * Runs the server while listening for shutdown signals
- */
+// limit resolves a configured limit: zero means the default, negative means none
+func limit[T int | int64 | time.Duration](value, def T) T {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// Start runs the server until SIGINT or SIGTERM, then shuts it down gracefully
 func (a *App) Start() error {
 	// Listen for shutdown signals
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Hand over to a new process on the upgrade signal, if enabled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go a.handleUpgrades(ctx, cancel)
+
+	if err := a.Run(ctx); err != nil {
+		return err
+	}
+
+	fmt.Println("Server shutdown successfully")
+	return nil
+}
+
+// Run serves until ctx is done or Shutdown is called, then drains the server
+// and runs the shutdown hooks. It returns once shutdown is complete.
+func (a *App) Run(ctx context.Context) error {
+	tlsConfig, err := a.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("TLS setup failed: %w", err)
+	}
+	a.server.TLSConfig = tlsConfig
+
+	ln, err := a.listen()
+	if err != nil {
+		return err
+	}
+	aux, err := a.listenAux()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	a.startOnce.Do(func() { close(a.started) })
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go a.watchCertificate(watchCtx)
+
 	// Start the server in separate goroutine
+	serveErr := make(chan error, 1+len(aux))
+	a.ready.Store(true)
 	go func() {
-		fmt.Printf("Server running on %s\n", a.server.Addr)
+		fmt.Printf("Server running on %s\n", ln.Addr())
 
-		var err error
-		if a.HostConfig.HttpsCertFile == "" || a.HostConfig.HttpsKeyFile == "" {
+		if tlsConfig == nil {
 			fmt.Println("WARNING: Starting server without TLS")
-			err = a.server.ListenAndServe()
+			serveErr <- a.server.Serve(ln)
 		} else {
-			err = a.server.ListenAndServeTLS(a.HostConfig.HttpsCertFile, a.HostConfig.HttpsKeyFile)
+			// Certificates come from TLSConfig
+			serveErr <- a.server.ServeTLS(ln, "", "")
 		}
-		if err != nil && err != http.ErrServerClosed {
+	}()
+	for _, srv := range aux {
+		go func() {
+			fmt.Printf("%s listener running on %s\n", srv.name, srv.listener.Addr())
+			serveErr <- srv.server.Serve(srv.listener)
+		}()
+	}
+	notifyUpgradeReady()
+
+	// Wait for cancellation, an explicit Shutdown or a server failure
+	select {
+	case <-ctx.Done():
+		fmt.Println("Shutdown signal received, shutting down server...")
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
 			fmt.Printf("Server failed: %v\n", err)
-			stop()
+			a.Shutdown(context.Background())
+			return fmt.Errorf("server failed: %w", err)
 		}
-	}()
+	}
+
+	return a.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting connections, drains in-flight requests and runs the
+// shutdown hooks. The drain ends at ctx's deadline or HostConfig.ShutdownTimeout,
+// whichever comes first. Only the first call does the work; later calls wait
+// for it and return its result.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		a.shutdownErr = a.shutdown(ctx)
+	})
+	return a.shutdownErr
+}
 
-	// Wait for shutdown signal
-	<-ctx.Done()
-	fmt.Println("Shutdown signal received, shutting down server...")
+// Addr returns the address the server is bound to once it is listening,
+// which resolves port "0" to the real port. Before that it returns the configured address.
+func (a *App) Addr() string {
+	a.listenerMu.Lock()
+	defer a.listenerMu.Unlock()
 
-	// Call all shutdown hooks
-	for _, hook := range a.onShutdown {
-		hook()
+	if a.listener != nil {
+		return a.listener.Addr().String()
 	}
+	return a.server.Addr
+}
 
-	// Shutdown gracefully
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
+// Started returns a channel that is closed once every listener is open
+func (a *App) Started() <-chan struct{} {
+	return a.started
+}
 
-	if err := a.server.Shutdown(shutdownCtx); err != nil {
-		return fmt.Errorf("server shutdown failed: %v", err)
+func (a *App) listen() (net.Listener, error) {
+	ln, inherited, err := inheritedListener(mainListenerName)
+	if !inherited && err == nil {
+		ln, err = listenAddress(a.server.Addr, a.HostConfig.SocketMode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s failed: %w", a.server.Addr, err)
 	}
 
-	fmt.Println("Server shutdown successfully")
-	return nil
+	a.listenerMu.Lock()
+	a.listener = ln
+	a.listenerMu.Unlock()
+
+	return ln, nil
 }
@@ -0,0 +1,152 @@
+package gex
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startApp runs app until the test ends and waits for it to listen
+func startApp(t *testing.T, app *App) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	select {
+	case <-app.Started():
+	case err := <-done:
+		t.Fatalf("Run = %v before listening", err)
+	}
+}
+
+func TestRunOnPortZero(t *testing.T) {
+	app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0"}, http.NotFound)
+	app.AddRoute("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	if got := app.Addr(); got != "127.0.0.1:0" {
+		t.Errorf("Addr before Run = %q, want the configured address", got)
+	}
+	startApp(t, app)
+
+	if addr := app.Addr(); addr == "127.0.0.1:0" || !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Fatalf("Addr after Run = %q, want the bound port", addr)
+	}
+	if !app.IsReady() {
+		t.Error("IsReady = false while serving")
+	}
+
+	resp, err := http.Get("http://" + app.Addr() + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Errorf("body = %q, want pong", body)
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0"}, http.NotFound)
+	entered, release := make(chan struct{}), make(chan struct{})
+	app.AddRoute("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("done"))
+	})
+	var hookRan atomic.Bool
+	app.OnShutdown(func() { hookRan.Store(true) })
+	startApp(t, app)
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + app.Addr() + "/slow")
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		got <- result{body: string(body)}
+	}()
+	<-entered
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- app.Shutdown(context.Background()) }()
+
+	// Draining waits for the request, and post-drain hooks wait for draining
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown = %v before the in-flight request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if app.IsReady() {
+		t.Error("IsReady = true while draining")
+	}
+	if hookRan.Load() {
+		t.Error("OnShutdown hook ran before draining finished")
+	}
+
+	close(release)
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v, want done", r.body, r.err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if !hookRan.Load() {
+		t.Error("OnShutdown hook did not run")
+	}
+	if _, err := http.Get("http://" + app.Addr() + "/slow"); err == nil {
+		t.Error("request accepted after Shutdown")
+	}
+}
+
+func TestShutdownTwice(t *testing.T) {
+	app := NewApp(HostConfig{}, nil)
+	hookErr := errors.New("flush failed")
+	var calls atomic.Int32
+	app.OnShutdownPhase(Final, func(context.Context) error {
+		calls.Add(1)
+		return hookErr
+	})
+
+	first := app.Shutdown(context.Background())
+	second := app.Shutdown(context.Background())
+
+	if !errors.Is(first, hookErr) || !errors.Is(second, hookErr) {
+		t.Errorf("Shutdown = %v, then %v, want both to report the hook error", first, second)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("hook ran %d times, want 1", n)
+	}
+}
+
+func TestShutdownDelayEndsWithContext(t *testing.T) {
+	app := NewApp(HostConfig{ShutdownDelay: time.Hour}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	app.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, want it to stop waiting when ctx is done", elapsed)
+	}
+}
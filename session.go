@@ -0,0 +1,40 @@
+package gex
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// SessionTokenHeader carries a refreshed or newly minted auth token back to the client.
+// Browser clients on another origin need it listed in CORSConfig.ExposedHeaders.
+const SessionTokenHeader = "X-Auth-Token"
+
+// SessionMiddleware resolves the request's session once with provider and stores
+// the result in the request context, where sessionprovider.FromContext finds it.
+// When the provider refreshed the session or minted a new token, the token is
+// written back in the X-Auth-Token response header.
+func SessionMiddleware(provider sessionprovider.SessionProvider) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := provider.GetSessionFromRequest(r)
+			if err != nil || result == nil || result.Session == nil {
+				WriteError(w, r, ErrInternal.Wrap(err))
+				return
+			}
+
+			if key, ok := result.Session.Get("key"); ok {
+				if sessionKey, ok := key.(string); ok {
+					AddLogAttrs(r.Context(), slog.String("session_key_hash", SessionKeyHash(sessionKey)))
+				}
+			}
+
+			if (result.DidAutoRefresh || result.DidMintToken) && result.AuthToken != "" {
+				w.Header().Set(SessionTokenHeader, result.AuthToken)
+			}
+
+			next.ServeHTTP(w, r.WithContext(sessionprovider.NewContext(r.Context(), result)))
+		})
+	}
+}
@@ -35,6 +35,14 @@ func (s *Container) Sessions() *map[string]SessionStorer {
 	return &s.sessions
 }
 
+// Count returns the number of sessions in the container.
+func (s *Container) Count() int {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	return len(s.sessions)
+}
+
 // InitSession is used to initialize a session with a given key.
 // It accepts a session object to initialize the session with.
 func (s *Container) InitSession(sessionKey string, sess SessionStorer) (SessionStorer, bool) {
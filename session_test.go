@@ -0,0 +1,119 @@
+package gex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/sessionprovider"
+)
+
+// stubProvider returns a fixed session result
+type stubProvider struct {
+	result *sessionprovider.SessionResult
+	err    error
+}
+
+func (p stubProvider) GetSessionFromRequest(r *http.Request) (*sessionprovider.SessionResult, error) {
+	return p.result, p.err
+}
+
+func newStubSession(key string) session.SessionStorer {
+	sess := session.NewInMemorySession()
+	if key != "" {
+		sess.Put("key", key)
+	}
+	return sess
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   stubProvider
+		wantStatus int
+		wantToken  string
+		wantHash   string
+	}{
+		{
+			name:       "existing session",
+			provider:   stubProvider{result: &sessionprovider.SessionResult{Session: newStubSession("k1"), AuthToken: "tok"}},
+			wantStatus: http.StatusOK,
+			wantHash:   SessionKeyHash("k1"),
+		},
+		{
+			name:       "minted token is sent back",
+			provider:   stubProvider{result: &sessionprovider.SessionResult{Session: newStubSession("k2"), DidMintToken: true, AuthToken: "new"}},
+			wantStatus: http.StatusOK,
+			wantToken:  "new",
+			wantHash:   SessionKeyHash("k2"),
+		},
+		{
+			name:       "refreshed token is sent back",
+			provider:   stubProvider{result: &sessionprovider.SessionResult{Session: newStubSession("k3"), DidAutoRefresh: true, AuthToken: "fresh"}},
+			wantStatus: http.StatusOK,
+			wantToken:  "fresh",
+			wantHash:   SessionKeyHash("k3"),
+		},
+		{
+			name:       "session without key",
+			provider:   stubProvider{result: &sessionprovider.SessionResult{Session: newStubSession("")}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "provider error",
+			provider:   stubProvider{err: errors.New("store down")},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "nil session",
+			provider:   stubProvider{result: &sessionprovider.SessionResult{}},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			var got *sessionprovider.SessionResult
+			h := AccessLogMiddleware(AccessLogConfig{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})(
+				SessionMiddleware(tt.provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got, _ = sessionprovider.FromContext(r.Context())
+				})))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && got != tt.provider.result {
+				t.Errorf("context result = %v, want the provider's", got)
+			}
+			if token := rec.Header().Get(SessionTokenHeader); token != tt.wantToken {
+				t.Errorf("%s = %q, want %q", SessionTokenHeader, token, tt.wantToken)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("log %q: %v", logs.String(), err)
+			}
+			if hash, _ := entry["session_key_hash"].(string); hash != tt.wantHash {
+				t.Errorf("session_key_hash = %q, want %q", hash, tt.wantHash)
+			}
+		})
+	}
+}
+
+func TestSessionKeyHash(t *testing.T) {
+	if got := SessionKeyHash(""); got != "" {
+		t.Errorf("SessionKeyHash(\"\") = %q, want empty", got)
+	}
+	a, b := SessionKeyHash("a"), SessionKeyHash("b")
+	if len(a) != 12 || a == b || a == "a" {
+		t.Errorf("SessionKeyHash = %q, %q, want distinct 12 character hashes", a, b)
+	}
+}
@@ -0,0 +1,29 @@
+package sessionprovider
+
+import "context"
+
+type contextKey struct{}
+
+type hooksKey struct{}
+
+// NewContext returns a copy of ctx carrying the session result
+func NewContext(ctx context.Context, result *SessionResult) context.Context {
+	return context.WithValue(ctx, contextKey{}, result)
+}
+
+// FromContext returns the session result stored by the session middleware, if any
+func FromContext(ctx context.Context) (*SessionResult, bool) {
+	result, ok := ctx.Value(contextKey{}).(*SessionResult)
+	return result, ok && result != nil
+}
+
+// ContextWithHooks returns a copy of ctx whose session provider events go to hooks
+func ContextWithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+// hooksFromContext returns the hooks in ctx, or nil; nil hooks do nothing
+func hooksFromContext(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return hooks
+}
@@ -1,6 +1,8 @@
 package sessionprovider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,6 +10,7 @@ import (
 	"github.com/golang-jwt/jwt"
 	"github.com/i247app/gex/jwtutil"
 	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/tracing"
 	"github.com/i247app/gex/util"
 )
 
@@ -15,6 +18,7 @@ type JwtResult struct {
 	JwtToken   *jwt.Token
 	SessionKey string
 	AuthToken  string
+	IsNew      bool
 }
 
 // JwtSessionProvider implements SessionProvider for JWT-based authentication
@@ -41,7 +45,12 @@ func NewJwtSessionProvider(
 }
 
 // GetSessionWithMetadata implements SessionProvider interface with additional metadata
-func (j *JwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionResult, error) {
+func (j *JwtSessionProvider) GetSessionFromRequest(r *http.Request) (_ *SessionResult, err error) {
+	ctx, span := tracing.Start(r.Context(), "session.GetSessionFromRequest")
+	span.SetAttribute("session.provider", "jwt")
+	defer func() { endSpan(span, err) }()
+	r = r.WithContext(ctx)
+
 	var didAutoRefresh bool
 
 	// 1. Get or create a JWT token
@@ -66,7 +75,9 @@ func (j *JwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionRes
 	isExpired, err := j.isSessionExpired(sess)
 	if isExpired || err != nil {
 		didAutoRefresh = true
-		log(">> JwtSessionProvider: session expired, auto-refreshing...")
+		span.SetAttribute("session.auto_refreshed", true)
+		hooksFromContext(r.Context()).sessionAutoRefreshed()
+		logCtx(r.Context(), ">> JwtSessionProvider: session expired, auto-refreshing...")
 		sess, err = j.refreshSession(sess)
 		if err != nil {
 			return nil, fmt.Errorf("error refreshing expired session: %w", err)
@@ -76,10 +87,14 @@ func (j *JwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionRes
 	// 4. Update session touched_at
 	sess.Put("touched_at", time.Now())
 
+	claims, _ := jwtResult.JwtToken.Claims.(*jwtutil.CustomClaims)
+
 	return &SessionResult{
 		Session:        sess,
 		DidAutoRefresh: didAutoRefresh,
+		DidMintToken:   jwtResult.IsNew,
 		AuthToken:      authToken,
+		Claims:         claims,
 	}, nil
 }
 
@@ -89,13 +104,16 @@ func (j *JwtSessionProvider) getOrCreateJwtToken(r *http.Request) (*JwtResult, e
 		return jwtResult, nil
 	}
 
-	// Failed to get a valid JWT token from the request
+	// Failed to get a valid JWT token from the request. Having none is not a failure.
+	if !errors.Is(err, ErrNoAuthorizationToken) && !errors.Is(err, ErrEmptyBearerToken) {
+		hooksFromContext(r.Context()).jwtValidationFailed(jwtFailureReason(err))
+	}
 	if err == ErrMalformedJwt {
-		log(">> JwtSessionProvider: WARNING ignoring your jwt token - totally malformed JWT token")
+		logCtx(r.Context(), ">> JwtSessionProvider: WARNING ignoring your jwt token - totally malformed JWT token")
 	} else if jwtResult == nil || jwtResult.JwtToken == nil || jwtResult.SessionKey == "" || jwtResult.AuthToken == "" || err != nil {
-		log(">> JwtSessionProvider: WARNING ignoring your jwt token - error getting JWT from request:", err)
+		logCtx(r.Context(), ">> JwtSessionProvider: WARNING ignoring your jwt token - error getting JWT from request:", err)
 	} else {
-		log(">> JwtSessionProvider: jwt ok")
+		logCtx(r.Context(), ">> JwtSessionProvider: jwt ok")
 	}
 
 	// Create a new JWT token with a new session key
@@ -105,7 +123,7 @@ func (j *JwtSessionProvider) getOrCreateJwtToken(r *http.Request) (*JwtResult, e
 		return nil, fmt.Errorf("error creating new JWT token: %v", err)
 	}
 
-	authToken, err := j.getAuthTokenFromJwtToken(jwtToken)
+	authToken, err := j.getAuthTokenFromJwtToken(r.Context(), jwtToken)
 	if authToken == "" || err != nil {
 		return nil, fmt.Errorf("error getting authToken from JWT token: %v", err)
 	}
@@ -114,13 +132,14 @@ func (j *JwtSessionProvider) getOrCreateJwtToken(r *http.Request) (*JwtResult, e
 		JwtToken:   jwtToken,
 		SessionKey: sessionKey,
 		AuthToken:  authToken,
+		IsNew:      true,
 	}, nil
 }
 
-func (j *JwtSessionProvider) getAuthTokenFromJwtToken(jwtToken *jwt.Token) (string, error) {
+func (j *JwtSessionProvider) getAuthTokenFromJwtToken(ctx context.Context, jwtToken *jwt.Token) (string, error) {
 	authToken, err := j.jwtHelper.SignToken(jwtToken)
 	if authToken == "" || err != nil {
-		log(">> JwtSessionProvider: error signing JWT:", err)
+		logCtx(ctx, ">> JwtSessionProvider: error signing JWT:", err)
 		return "", err
 	}
 	return authToken, nil
@@ -130,32 +149,35 @@ func (j *JwtSessionProvider) getValidJwtFromRequest(r *http.Request) (*JwtResult
 	// Validate Authorization header
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return nil, fmt.Errorf("no Authorization header found")
+		return nil, ErrNoAuthorizationToken
 	}
 
 	if authHeader == "Bearer " {
-		return nil, fmt.Errorf("no JWT token found in Authorization header")
+		return nil, ErrEmptyBearerToken
 	}
 
 	// Get JWT Token
+	_, span := tracing.Start(r.Context(), "jwt.verify")
 	jwtToken, err := jwtutil.GetAuthorizationHeaderJwt(authHeader, j.jwtHelper)
 	if jwtToken == nil || err != nil {
+		endSpan(span, fmt.Errorf("%w: %v", ErrMalformedJwt, err))
 		return nil, ErrMalformedJwt
 	}
+	span.End()
 
 	// Validate JWT Token
 	claims, ok := jwtToken.Claims.(*jwtutil.CustomClaims)
 	if claims == nil || !ok {
-		return nil, fmt.Errorf("invalid JWT token")
+		return nil, ErrInvalidJwtClaims
 	}
 
 	// Validate Session Key
 	if claims.SessionKey == "" {
-		return nil, fmt.Errorf("no session key found in JWT token")
+		return nil, ErrNoSessionKey
 	}
 
 	// Extract authToken from JWT token
-	authToken, err := j.getAuthTokenFromJwtToken(jwtToken)
+	authToken, err := j.getAuthTokenFromJwtToken(r.Context(), jwtToken)
 	if authToken == "" || err != nil {
 		return nil, fmt.Errorf("error getting authToken from JWT token: %v", err)
 	}
@@ -1,12 +1,32 @@
 package sessionprovider
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+
+	"github.com/i247app/gex/requestid"
+	"github.com/i247app/gex/tracing"
 )
 
 var log = fmt.Println
 
+// logCtx logs args prefixed with the request ID carried by ctx, if any
+func logCtx(ctx context.Context, args ...any) {
+	if id := requestid.FromContext(ctx); id != "" {
+		args = append([]any{"[" + id + "]"}, args...)
+	}
+	log(args...)
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span tracing.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
 type SessionProvider interface {
 	GetSessionFromRequest(r *http.Request) (*SessionResult, error)
 }
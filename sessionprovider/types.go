@@ -3,18 +3,63 @@ package sessionprovider
 import (
 	"errors"
 
+	"github.com/i247app/gex/jwtutil"
 	"github.com/i247app/gex/session"
 )
 
 var (
-	ErrMalformedJwt = errors.New("invalid or malformed JWT")
+	ErrMalformedJwt         = errors.New("invalid or malformed JWT")
+	ErrNoAuthorizationToken = errors.New("no Authorization header found")
+	ErrEmptyBearerToken     = errors.New("no JWT token found in Authorization header")
+	ErrInvalidJwtClaims     = errors.New("invalid JWT token")
+	ErrNoSessionKey         = errors.New("no session key found in JWT token")
 )
 
+// Hooks are called on session provider events, e.g. to count them in metrics.
+// The providers take them from the request context, see ContextWithHooks.
+// Nil funcs are skipped; the others must be safe for concurrent use.
+type Hooks struct {
+	// JwtValidationFailure is called with a short reason whenever a request's JWT
+	// is rejected and a new one is minted in its place. Requests without a token
+	// are not failures and are not reported.
+	JwtValidationFailure func(reason string)
+
+	// SessionAutoRefresh is called whenever an expired session is refreshed automatically
+	SessionAutoRefresh func()
+}
+
+func (h *Hooks) jwtValidationFailed(reason string) {
+	if h != nil && h.JwtValidationFailure != nil {
+		h.JwtValidationFailure(reason)
+	}
+}
+
+func (h *Hooks) sessionAutoRefreshed() {
+	if h != nil && h.SessionAutoRefresh != nil {
+		h.SessionAutoRefresh()
+	}
+}
+
+// jwtFailureReason names the reason a JWT was rejected
+func jwtFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMalformedJwt):
+		return "malformed"
+	case errors.Is(err, ErrInvalidJwtClaims):
+		return "invalid_claims"
+	case errors.Is(err, ErrNoSessionKey):
+		return "no_session_key"
+	}
+	return "other"
+}
+
 type SessionFactory func() session.SessionStorer
 
 // SessionResult contains the session and metadata about the session retrieval
 type SessionResult struct {
 	Session        session.SessionStorer
 	DidAutoRefresh bool
+	DidMintToken   bool // AuthToken is new; the request carried no usable token
 	AuthToken      string
+	Claims         *jwtutil.CustomClaims // nil for providers without JWT claims
 }
@@ -8,11 +8,13 @@ import (
 
 	"github.com/i247app/gex/jwtutil"
 	"github.com/i247app/gex/session"
+	"github.com/i247app/gex/tracing"
 )
 
 type XwtResult struct {
 	XwtToken   string
 	SessionKey string
+	IsNew      bool
 }
 
 // XwtSessionProvider implements SessionProvider for XWT-based authentication
@@ -39,7 +41,12 @@ func NewXwtSessionProvider(
 }
 
 // GetSessionWithMetadata implements SessionProvider interface with additional metadata
-func (x *XwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionResult, error) {
+func (x *XwtSessionProvider) GetSessionFromRequest(r *http.Request) (_ *SessionResult, err error) {
+	ctx, span := tracing.Start(r.Context(), "session.GetSessionFromRequest")
+	span.SetAttribute("session.provider", "xwt")
+	defer func() { endSpan(span, err) }()
+	r = r.WithContext(ctx)
+
 	var didAutoRefresh bool
 
 	// 1. Get or create a XWT token
@@ -64,7 +71,9 @@ func (x *XwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionRes
 	isExpired, err := x.isSessionExpired(sess)
 	if isExpired || err != nil {
 		didAutoRefresh = true
-		log(">> XwtSessionProvider: session expired, auto-refreshing...")
+		span.SetAttribute("session.auto_refreshed", true)
+		hooksFromContext(r.Context()).sessionAutoRefreshed()
+		logCtx(r.Context(), ">> XwtSessionProvider: session expired, auto-refreshing...")
 		sess, err = x.refreshSession(sess)
 		if err != nil {
 			return nil, fmt.Errorf("error refreshing expired session: %w", err)
@@ -77,6 +86,7 @@ func (x *XwtSessionProvider) GetSessionFromRequest(r *http.Request) (*SessionRes
 	return &SessionResult{
 		Session:        sess,
 		DidAutoRefresh: didAutoRefresh,
+		DidMintToken:   xwtResult.IsNew,
 		AuthToken:      authToken,
 	}, nil
 }
@@ -89,9 +99,9 @@ func (x *XwtSessionProvider) getOrCreateXwtToken(r *http.Request) (*XwtResult, e
 
 	// Failed to get a valid XWT token from the request
 	if xwtResult == nil || xwtResult.XwtToken == "" || xwtResult.SessionKey == "" || err != nil {
-		log(">> XwtSessionProvider: WARNING ignoring your xwt token - error getting XWT from request:", err)
+		logCtx(r.Context(), ">> XwtSessionProvider: WARNING ignoring your xwt token - error getting XWT from request:", err)
 	} else {
-		log(">> XwtSessionProvider: xwt ok")
+		logCtx(r.Context(), ">> XwtSessionProvider: xwt ok")
 	}
 
 	// Create a new XWT token with a new session key
@@ -140,6 +150,7 @@ func (x *XwtSessionProvider) createNewXwtToken() (*XwtResult, error) {
 	return &XwtResult{
 		XwtToken:   signedToken,
 		SessionKey: signedToken,
+		IsNew:      true,
 	}, nil
 }
 
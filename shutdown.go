@@ -0,0 +1,116 @@
+package gex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default shutdown timings, used when the matching HostConfig field is zero
+const (
+	DefaultShutdownTimeout     = 10 * time.Second
+	DefaultShutdownHookTimeout = 5 * time.Second
+)
+
+// ShutdownPhase says when a shutdown hook runs relative to request draining
+type ShutdownPhase int
+
+const (
+	// PreDrain hooks run once the app reports not ready, while requests are still being served
+	PreDrain ShutdownPhase = iota
+	// PostDrain hooks run after in-flight requests have finished or the drain timeout expired
+	PostDrain
+	// Final hooks run last, after every PostDrain hook
+	Final
+
+	shutdownPhaseCount
+)
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PreDrain:
+		return "pre-drain"
+	case PostDrain:
+		return "post-drain"
+	case Final:
+		return "final"
+	}
+	return fmt.Sprintf("ShutdownPhase(%d)", int(p))
+}
+
+// ShutdownHook is a cleanup step run during shutdown.
+// The context carries the per-hook deadline from HostConfig.ShutdownHookTimeout.
+type ShutdownHook func(ctx context.Context) error
+
+// OnShutdown registers a cleanup func that runs after in-flight requests are drained
+func (a *App) OnShutdown(cleanupFunc func()) {
+	a.OnShutdownPhase(PostDrain, func(context.Context) error {
+		cleanupFunc()
+		return nil
+	})
+}
+
+// OnShutdownPhase registers a hook for the given phase.
+// Hooks in a phase run in registration order.
+func (a *App) OnShutdownPhase(phase ShutdownPhase, hook ShutdownHook) {
+	if phase < 0 || phase >= shutdownPhaseCount {
+		panic(fmt.Sprintf("gex: invalid shutdown phase %d", phase))
+	}
+	a.shutdownHooks[phase] = append(a.shutdownHooks[phase], hook)
+}
+
+// IsReady reports whether the app is serving and not draining
+func (a *App) IsReady() bool {
+	return a.ready.Load()
+}
+
+// shutdown marks the app not ready, drains in-flight requests and runs
+// the shutdown hooks phase by phase. Errors are collected, not fatal.
+func (a *App) shutdown(ctx context.Context) error {
+	a.ready.Store(false)
+
+	errs := a.runShutdownHooks(PreDrain)
+
+	// Give load balancers time to see the app as not ready, unless ctx is already done
+	if a.HostConfig.ShutdownDelay > 0 {
+		select {
+		case <-time.After(a.HostConfig.ShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	// Drain in-flight requests
+	drainCtx, cancel := timeoutContext(ctx, limit(a.HostConfig.ShutdownTimeout, DefaultShutdownTimeout))
+	defer cancel()
+
+	errs = append(errs, a.shutdownServers(drainCtx)...)
+
+	errs = append(errs, a.runShutdownHooks(PostDrain)...)
+	errs = append(errs, a.runShutdownHooks(Final)...)
+
+	return errors.Join(errs...)
+}
+
+func (a *App) runShutdownHooks(phase ShutdownPhase) []error {
+	timeout := limit(a.HostConfig.ShutdownHookTimeout, DefaultShutdownHookTimeout)
+
+	var errs []error
+	for i, hook := range a.shutdownHooks[phase] {
+		ctx, cancel := timeoutContext(context.Background(), timeout)
+		if err := hook(ctx); err != nil {
+			fmt.Printf("Shutdown hook %s #%d failed: %v\n", phase, i, err)
+			errs = append(errs, fmt.Errorf("%s hook #%d: %w", phase, i, err))
+		}
+		cancel()
+	}
+	return errs
+}
+
+// timeoutContext derives a context with timeout d from parent, or with no extra deadline if d <= 0
+func timeoutContext(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
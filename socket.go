@@ -0,0 +1,200 @@
+package gex
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSocketMode is the permission of Unix sockets created by the app
+// when HostConfig.SocketMode is zero
+const DefaultSocketMode fs.FileMode = 0o660
+
+// Address prefixes understood by HostConfig.Address
+const (
+	unixAddressPrefix    = "unix:"
+	systemdAddressPrefix = "systemd"
+)
+
+// listenAddress opens a listener for a HostConfig.Address style address:
+// "host:port", "unix:/path/to.sock", "systemd" or "systemd:<name>"
+func listenAddress(addr string, socketMode fs.FileMode) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixAddressPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixAddressPrefix), socketMode)
+	case addr == systemdAddressPrefix || strings.HasPrefix(addr, systemdAddressPrefix+":"):
+		return systemdListener(strings.TrimPrefix(strings.TrimPrefix(addr, systemdAddressPrefix), ":"))
+	}
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix listens on a Unix socket at path, replacing a stale socket file
+// left by a previous run, and applies mode to it
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("empty unix socket path")
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket: %w", err)
+		}
+	}
+
+	// Create the socket in a private directory and move it into place once
+	// it has its mode, so it is never reachable with wider permissions
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, fmt.Errorf("error creating socket directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tmpPath := filepath.Join(tmp, "s")
+	ln, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	ul := ln.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false)
+
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		ul.Close()
+		return nil, fmt.Errorf("error setting socket permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		ul.Close()
+		return nil, fmt.Errorf("error moving socket into place: %w", err)
+	}
+	return &unixListener{UnixListener: ul, path: path, unlink: true}, nil
+}
+
+// unixListener removes its socket file on Close, as net.UnixListener does
+// for the name it was bound to, which listenUnix renamed
+type unixListener struct {
+	*net.UnixListener
+	path string
+
+	mu     sync.Mutex
+	unlink bool
+}
+
+// SetUnlinkOnClose sets whether Close removes the socket file
+func (l *unixListener) SetUnlinkOnClose(unlink bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlink = unlink
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unlink {
+		l.unlink = false
+		os.Remove(l.path)
+	}
+	return err
+}
+
+// systemd passes sockets starting at file descriptor 3, see sd_listen_fds(3)
+const systemdFirstFD = 3
+
+var (
+	systemdOnce      sync.Once
+	systemdMu        sync.Mutex
+	systemdListeners []namedListener
+	systemdErr       error
+)
+
+type namedListener struct {
+	name     string
+	listener net.Listener
+}
+
+// systemdListener returns a socket passed by systemd socket activation,
+// matched by its FileDescriptorName, or the first one when name is empty.
+// Each socket can be taken once.
+func systemdListener(name string) (net.Listener, error) {
+	systemdOnce.Do(func() {
+		systemdListeners, systemdErr = inheritSystemdListeners()
+	})
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+
+	systemdMu.Lock()
+	defer systemdMu.Unlock()
+
+	for i, l := range systemdListeners {
+		if name == "" || l.name == name {
+			systemdListeners = append(systemdListeners[:i], systemdListeners[i+1:]...)
+			return l.listener, nil
+		}
+	}
+	if name == "" {
+		return nil, errors.New("no socket passed by systemd (LISTEN_FDS)")
+	}
+	return nil, fmt.Errorf("no socket named %q passed by systemd (LISTEN_FDNAMES)", name)
+}
+
+// inheritSystemdListeners reads LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES,
+// then unsets them so child processes don't claim the same sockets
+func inheritSystemdListeners() ([]namedListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	return fileListeners(systemdFirstFD, count, names)
+}
+
+// fileListeners wraps count inherited file descriptors starting at first
+func fileListeners(first, count int, names []string) ([]namedListener, error) {
+	listeners := make([]namedListener, 0, count)
+	for i := range count {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(first+i), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.listener.Close()
+			}
+			return nil, fmt.Errorf("inherited file descriptor %d is not a listening socket: %w", first+i, err)
+		}
+		listeners = append(listeners, namedListener{name: name, listener: ln})
+	}
+	return listeners, nil
+}
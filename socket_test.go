@@ -0,0 +1,119 @@
+//go:build unix
+
+package gex
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tt := range []struct {
+		name string
+		mode fs.FileMode
+		want fs.FileMode
+	}{
+		{"default mode", 0, DefaultSocketMode},
+		{"owner only", 0o600, 0o600},
+		{"world", 0o666, 0o666},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".sock")
+			ln, err := listenUnix(path, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("mode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenUnixLeavesUmask(t *testing.T) {
+	old := syscall.Umask(0o022)
+	defer syscall.Umask(old)
+
+	ln, err := listenUnix(filepath.Join(t.TempDir(), "app.sock"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	if got := syscall.Umask(0o022); got != 0o022 {
+		t.Errorf("umask = %#o after listenUnix, want 022", got)
+	}
+}
+
+func TestListenUnixCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.sock")
+
+	ln, err := listenUnix(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing the socket: %v", err)
+	}
+	conn.Close()
+
+	// Only the socket is left next to it, not the directory it was created in
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "app.sock" {
+		t.Errorf("directory holds %v, want only app.sock", entries)
+	}
+
+	ln.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+}
+
+func TestListenUnixStaleAndInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	ln, err := listenUnix(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(path, 0); err == nil {
+		t.Error("listening on a socket in use succeeded")
+	}
+
+	// Leave the socket file behind as a crashed process would
+	ln.(interface{ SetUnlinkOnClose(bool) }).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("stale socket missing: %v", err)
+	}
+
+	ln, err = listenUnix(path, 0)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	ln.Close()
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(path, 0); err == nil {
+		t.Error("listening over a regular file succeeded")
+	}
+}
@@ -0,0 +1,218 @@
+package gex
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultCertReloadInterval is how often certificate files are checked for
+// changes when HostConfig.CertReloadInterval is zero
+const DefaultCertReloadInterval = time.Minute
+
+// tlsEnabled reports whether the app serves HTTPS
+func (a *App) tlsEnabled() bool {
+	hc := a.HostConfig
+	return (hc.HttpsCertFile != "" && hc.HttpsKeyFile != "") || hc.ConfigureTLS != nil
+}
+
+// tlsConfig builds the server TLS config, or returns nil when TLS is off.
+// Certificates come from HttpsCertFile/HttpsKeyFile through a reloader,
+// so renewed files are picked up without a restart.
+func (a *App) tlsConfig() (*tls.Config, error) {
+	if !a.tlsEnabled() {
+		return nil, nil
+	}
+	hc := a.HostConfig
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if hc.HttpsCertFile != "" && hc.HttpsKeyFile != "" {
+		certs := &certReloader{certFile: hc.HttpsCertFile, keyFile: hc.HttpsKeyFile}
+		if err := certs.load(); err != nil {
+			return nil, err
+		}
+		cfg.GetCertificate = certs.getCertificate
+		a.certs = certs
+	}
+
+	if hc.ClientCAFile != "" {
+		pem, err := os.ReadFile(hc.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", hc.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = hc.ClientAuth
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	if hc.ConfigureTLS != nil {
+		hc.ConfigureTLS(cfg)
+	}
+
+	if cfg.GetCertificate == nil && cfg.GetConfigForClient == nil && len(cfg.Certificates) == 0 {
+		return nil, errors.New("TLS is configured without a certificate")
+	}
+	return cfg, nil
+}
+
+// ReloadCertificate reloads HttpsCertFile and HttpsKeyFile. On failure the
+// current certificate stays in use. Sending SIGHUP to the process does the same.
+func (a *App) ReloadCertificate() error {
+	if a.certs == nil {
+		return errors.New("no certificate files configured")
+	}
+	return a.certs.load()
+}
+
+// watchCertificate reloads the certificate on SIGHUP and whenever the files
+// change, until ctx is done
+func (a *App) watchCertificate(ctx context.Context) {
+	if a.certs == nil {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval := limit(a.HostConfig.CertReloadInterval, DefaultCertReloadInterval); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			fmt.Println("SIGHUP received, reloading TLS certificate")
+		case <-tick:
+			if !a.certs.changed() {
+				continue
+			}
+			fmt.Println("TLS certificate files changed, reloading")
+		}
+
+		if err := a.certs.load(); err != nil {
+			fmt.Printf("TLS certificate reload failed, keeping the current one: %v\n", err)
+		}
+	}
+}
+
+// certReloader serves a certificate loaded from disk and swaps it on reload
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (c *certReloader) load() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+// changed reports whether either file was modified since the last successful load
+func (c *certReloader) changed() bool {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !modTime.Equal(c.modTime)
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error reading TLS certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// ClientIdentity is the verified client certificate of a mutual TLS connection
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	Certificate    *x509.Certificate
+}
+
+type clientIdentityKey struct{}
+
+// ClientIdentityFromContext returns the verified client certificate identity
+// of the request, if the client presented one
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return id, ok
+}
+
+// clientIdentityMiddleware stores the verified client certificate in the request context
+func clientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		id := &ClientIdentity{
+			CommonName:     cert.Subject.CommonName,
+			DNSNames:       cert.DNSNames,
+			EmailAddresses: cert.EmailAddresses,
+			Certificate:    cert,
+		}
+		for _, uri := range cert.URIs {
+			id.URIs = append(id.URIs, uri.String())
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, id)))
+	})
+}
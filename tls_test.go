@@ -0,0 +1,241 @@
+package gex
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a generated certificate and key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate for cn signed by parent, or self-signed
+// when parent is nil
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{cn},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/" + cn}},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write stores the certificate and key as PEM files in dir
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	app := NewApp(HostConfig{HttpsCertFile: certFile, HttpsKeyFile: keyFile}, nil)
+	cfg, err := app.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servedCN := func() string {
+		cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+
+	if cn := servedCN(); cn != "first" {
+		t.Fatalf("serving %q, want first", cn)
+	}
+	if app.certs.changed() {
+		t.Error("changed() right after load")
+	}
+
+	// Replace the files with a new certificate, dated later so the change is seen
+	newTestCert(t, "second", nil, false).write(t, dir, "server")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if !app.certs.changed() {
+		t.Error("changed() = false after the files were replaced")
+	}
+	if err := app.ReloadCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("serving %q after reload, want second", cn)
+	}
+
+	// A broken file keeps the current certificate
+	os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if err := app.ReloadCertificate(); err == nil {
+		t.Error("reloading a broken certificate succeeded")
+	}
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("serving %q after a failed reload, want second", cn)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCert(t, "server", nil, false).write(t, dir, "server")
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("nothing here"), 0o600)
+
+	tests := []struct {
+		name string
+		hc   HostConfig
+	}{
+		{"missing key", HostConfig{HttpsCertFile: certFile, HttpsKeyFile: filepath.Join(dir, "missing.key")}},
+		{"client CA without certificates", HostConfig{HttpsCertFile: certFile, HttpsKeyFile: keyFile, ClientCAFile: garbage}},
+		{"hook without certificate", HostConfig{ConfigureTLS: func(*tls.Config) {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewApp(tt.hc, nil).tlsConfig(); err == nil {
+				t.Error("tlsConfig succeeded")
+			}
+		})
+	}
+
+	if cfg, err := NewApp(HostConfig{}, nil).tlsConfig(); cfg != nil || err != nil {
+		t.Errorf("tlsConfig without TLS = %v, %v, want nil, nil", cfg, err)
+	}
+	if err := NewApp(HostConfig{}, nil).ReloadCertificate(); err == nil {
+		t.Error("ReloadCertificate without certificate files succeeded")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test CA", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "127.0.0.1", ca, false).write(t, dir, "server")
+	client := newTestCert(t, "billing-service", ca, false)
+	stranger := newTestCert(t, "stranger", newTestCert(t, "other CA", nil, true), false)
+
+	app := NewApp(HostConfig{
+		ServerHost:    "127.0.0.1",
+		ServerPort:    "0",
+		HttpsCertFile: certFile,
+		HttpsKeyFile:  keyFile,
+		ClientCAFile:  caFile,
+	}, nil)
+	app.AddRoute("GET /whoami", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := ClientIdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, "no identity", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(id.CommonName + " " + id.URIs[0]))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-app.Started()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(cert *testCert) (string, error) {
+		tlsCfg := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			tlsCfg.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+		resp, err := c.Get("https://" + app.Addr() + "/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body := make([]byte, 256)
+		n, _ := resp.Body.Read(body)
+		return string(body[:n]), nil
+	}
+
+	if body, err := get(client); err != nil || body != "billing-service spiffe://example.org/billing-service" {
+		t.Errorf("trusted client got %q, %v", body, err)
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("client without a certificate was accepted")
+	}
+	if _, err := get(stranger); err == nil {
+		t.Error("client with an untrusted certificate was accepted")
+	}
+}
+
+func TestClientIdentityWithoutTLS(t *testing.T) {
+	var ok bool
+	h := clientIdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok = ClientIdentityFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if ok {
+		t.Error("plain HTTP request has a client identity")
+	}
+}
@@ -0,0 +1,56 @@
+package gex
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/i247app/gex/tracing"
+)
+
+// TracingMiddleware starts a server span per request with tracer, continuing the
+// trace of an incoming W3C traceparent header. The span is named after the
+// matched route pattern, or "HTTP <method>" for unmatched requests. The tracer
+// is put in the request context so tracing.Start creates child spans, e.g. in the
+// session providers.
+func TracingMiddleware(tracer tracing.Tracer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if tp, ok := parseTraceparent(r.Header.Get("Traceparent")); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, tracing.SpanContext{
+					TraceID: tp.traceID,
+					SpanID:  tp.parentID,
+					Sampled: tp.sampled(),
+				})
+			}
+
+			ctx, span := tracer.Start(ctx, "HTTP "+r.Method, tracing.SpanKindServer)
+			defer span.End()
+
+			st, r := withRequestState(r.WithContext(ctx))
+			rw := newResponseWriter(w)
+
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("client.address", ClientIP(r))
+			if id := RequestID(ctx); id != "" {
+				span.SetAttribute("request.id", id)
+			}
+
+			next.ServeHTTP(rw, r)
+
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.response.status_code", status)
+			if pattern := st.routePattern(); pattern != "" {
+				span.SetName(pattern)
+				span.SetAttribute("http.route", pattern)
+			}
+			if status >= 500 {
+				span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+			}
+		})
+	}
+}
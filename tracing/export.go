@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryExporter keeps finished spans in memory, e.g. for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpans implements Exporter
+func (e *InMemoryExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns the spans exported so far, in the order they ended
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData{}, e.spans...)
+}
+
+// Reset forgets all exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// BatchExporter buffers spans and forwards them to another exporter in batches,
+// when the batch is full or every interval, so span.End never waits on the network.
+// Spans are dropped if the buffer is full.
+type BatchExporter struct {
+	next      Exporter
+	batchSize int
+	spans     chan SpanData
+	flush     chan chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBatchExporter starts a batch exporter. Call Shutdown to flush and stop it.
+func NewBatchExporter(next Exporter, batchSize int, interval time.Duration) *BatchExporter {
+	if batchSize <= 0 {
+		batchSize = 512
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	b := &BatchExporter{
+		next:      next,
+		batchSize: batchSize,
+		spans:     make(chan SpanData, batchSize*4),
+		flush:     make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// ExportSpans implements Exporter
+func (b *BatchExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	for _, s := range spans {
+		select {
+		case b.spans <- s:
+		default:
+		}
+	}
+	return nil
+}
+
+// Shutdown exports buffered spans and stops the exporter
+func (b *BatchExporter) Shutdown(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		flushed := make(chan struct{})
+		select {
+		case b.flush <- flushed:
+			<-flushed
+		case <-ctx.Done():
+		}
+		close(b.done)
+	})
+	return ctx.Err()
+}
+
+func (b *BatchExporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, b.batchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.next.ExportSpans(context.Background(), batch)
+		batch = make([]SpanData, 0, b.batchSize)
+	}
+
+	for {
+		select {
+		case s := <-b.spans:
+			batch = append(batch, s)
+			if len(batch) >= b.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-b.flush:
+			for drained := false; !drained; {
+				select {
+				case s := <-b.spans:
+					batch = append(batch, s)
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(flushed)
+		case <-b.done:
+			return
+		}
+	}
+}
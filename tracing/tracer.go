@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Exporter receives finished spans
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+}
+
+// SimpleTracer is a Tracer that hands every finished span to an Exporter.
+// Wrap slow exporters, e.g. OTLP, in a BatchExporter.
+type SimpleTracer struct {
+	exporter Exporter
+}
+
+func NewTracer(exporter Exporter) *SimpleTracer {
+	return &SimpleTracer{exporter: exporter}
+}
+
+// Start implements Tracer
+func (t *SimpleTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	sc := SpanContext{SpanID: newID(8), Sampled: true}
+	var parentID string
+	if parent, ok := ParentFromContext(ctx); ok && parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		parentID = parent.SpanID
+	} else {
+		sc.TraceID = newID(16)
+	}
+
+	span := &span{
+		tracer: t,
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  sc,
+			ParentSpanID: parentID,
+			Start:        time.Now(),
+		},
+	}
+	return ContextWithTracer(ContextWithSpan(ctx, span), t), span
+}
+
+type span struct {
+	tracer *SimpleTracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+func (s *span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+func (s *span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Err = err
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.exporter.ExportSpans(context.Background(), []SpanData{data})
+	}
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
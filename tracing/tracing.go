@@ -0,0 +1,115 @@
+// Package tracing defines the small tracing interface gex instruments itself with,
+// plus a Tracer implementation with in-memory and OTLP/HTTP exporters.
+// Code that only creates spans should depend on Start and the Span interface,
+// so any tracer, e.g. an OpenTelemetry adapter, can be swapped in.
+package tracing
+
+import (
+	"context"
+	"time"
+)
+
+// SpanKind says what role a span plays in a trace
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+// SpanContext identifies a span within a trace. IDs are lowercase hex.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+	Remote  bool // received from another process, e.g. through a traceparent header
+}
+
+// IsValid reports whether the span context has both IDs
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// Span is a unit of work within a trace
+type Span interface {
+	SpanContext() SpanContext
+	SetName(name string)
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. The new span's parent is the span in ctx, if any,
+// else the remote parent set with ContextWithRemoteParent.
+type Tracer interface {
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+type remoteParentKey struct{}
+
+// ContextWithTracer returns a copy of ctx that Start will create spans with
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// ContextWithSpan returns a copy of ctx carrying span as the current span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the current span, or a no-op span if there is none
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+// ContextWithRemoteParent returns a copy of ctx whose next span continues the remote trace sc
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteParentKey{}, sc)
+}
+
+// ParentFromContext returns the span context a new span in ctx should use as parent
+func ParentFromContext(ctx context.Context) (SpanContext, bool) {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span.SpanContext(), true
+	}
+	sc, ok := ctx.Value(remoteParentKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Start starts a child span with the tracer carried by ctx.
+// Without a tracer it returns ctx unchanged and a no-op span, so instrumented
+// code costs next to nothing when tracing is off.
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, name, SpanKindInternal)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext { return SpanContext{} }
+func (noopSpan) SetName(string)           {}
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) RecordError(error)        {}
+func (noopSpan) End()                     {}
+
+// SpanData is a finished span, as handed to an Exporter
+type SpanData struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SpanContext
+	ParentSpanID string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]any
+	Err          error
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "noop")
+	if got != ctx || span.SpanContext().IsValid() {
+		t.Errorf("Start without a tracer = %v, %+v, want ctx unchanged and a no-op span", got, span.SpanContext())
+	}
+	span.End()
+}
+
+func TestSimpleTracer(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	_, child := Start(ctx, "child")
+	child.SetAttribute("n", 1)
+	child.RecordError(errors.New("failed"))
+	child.End()
+	child.End()
+	root.SetName("renamed")
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2 (End is idempotent)", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if r.Name != "renamed" || r.ParentSpanID != "" || len(r.SpanContext.TraceID) != 32 || len(r.SpanContext.SpanID) != 16 {
+		t.Errorf("root = %+v", r)
+	}
+	if c.SpanContext.TraceID != r.SpanContext.TraceID || c.ParentSpanID != r.SpanContext.SpanID || c.Kind != SpanKindInternal {
+		t.Errorf("child = %+v, want a child of %+v", c, r.SpanContext)
+	}
+	if c.Attributes["n"] != 1 || c.Err == nil || c.End.Before(c.Start) {
+		t.Errorf("child data = %+v", c)
+	}
+
+	exporter.Reset()
+	if n := len(exporter.Spans()); n != 0 {
+		t.Errorf("%d spans after Reset", n)
+	}
+}
+
+func TestSimpleTracerRemoteParent(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+	remote := SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}
+
+	_, span := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "unsampled", SpanKindServer)
+	span.End()
+	if n := len(exporter.Spans()); n != 0 {
+		t.Errorf("exported %d spans of an unsampled trace", n)
+	}
+
+	remote.Sampled = true
+	_, span = tracer.Start(ContextWithRemoteParent(context.Background(), remote), "sampled", SpanKindServer)
+	span.End()
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].SpanContext.TraceID != remote.TraceID || spans[0].ParentSpanID != remote.SpanID {
+		t.Errorf("spans = %+v, want one continuing the remote trace", spans)
+	}
+}
+
+// countingExporter records the size of every batch it receives
+type countingExporter struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (e *countingExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.batches = append(e.batches, len(spans))
+	return nil
+}
+
+func (e *countingExporter) total() (batches, spans int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, n := range e.batches {
+		spans += n
+	}
+	return len(e.batches), spans
+}
+
+func TestBatchExporter(t *testing.T) {
+	next := &countingExporter{}
+	b := NewBatchExporter(next, 2, time.Hour)
+
+	b.ExportSpans(context.Background(), []SpanData{{Name: "a"}, {Name: "b"}, {Name: "c"}})
+	deadline := time.Now().Add(time.Second)
+	for batches, _ := next.total(); batches == 0 && time.Now().Before(deadline); batches, _ = next.total() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if batches, spans := next.total(); batches != 2 || spans != 3 {
+		t.Errorf("got %d batches with %d spans, want a full batch of 2 and the rest flushed on Shutdown", batches, spans)
+	}
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpRequest
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	e := NewOTLPExporter(collector.URL, "svc")
+	e.Headers = map[string]string{"Authorization": "Bearer k"}
+	start := time.Unix(10, 0)
+	err := e.ExportSpans(context.Background(), []SpanData{{
+		Name:         "GET /users",
+		Kind:         SpanKindServer,
+		SpanContext:  SpanContext{TraceID: "t1", SpanID: "s1"},
+		ParentSpanID: "p1",
+		Start:        start,
+		End:          start.Add(time.Second),
+		Attributes:   map[string]any{"http.response.status_code": 500},
+		Err:          errors.New("500 Internal Server Error"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer k" {
+		t.Errorf("Authorization = %q, want the configured header", auth)
+	}
+	rs := got.ResourceSpans[0]
+	if rs.Resource.Attributes[0].Value["stringValue"] != "svc" {
+		t.Errorf("resource = %+v, want service.name svc", rs.Resource)
+	}
+	span := rs.ScopeSpans[0].Spans[0]
+	want := otlpSpan{
+		TraceID: "t1", SpanID: "s1", ParentSpanID: "p1", Name: "GET /users", Kind: 2,
+		StartTimeUnixNano: "10000000000", EndTimeUnixNano: "11000000000",
+		Status: otlpStatus{Code: 2, Message: "500 Internal Server Error"},
+	}
+	attrs := span.Attributes
+	span.Attributes = nil
+	if !reflect.DeepEqual(span, want) {
+		t.Errorf("span = %+v, want %+v", span, want)
+	}
+	if len(attrs) != 1 || attrs[0].Value["intValue"] != "500" {
+		t.Errorf("attributes = %+v, want an intValue of 500", attrs)
+	}
+}
+
+func TestOTLPExporterCollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	err := NewOTLPExporter(collector.URL, "svc").ExportSpans(context.Background(), []SpanData{{Name: "x"}})
+	if err == nil {
+		t.Error("ExportSpans succeeded against a failing collector")
+	}
+	if err := NewOTLPExporter(collector.URL, "svc").ExportSpans(context.Background(), nil); err != nil {
+		t.Errorf("exporting no spans = %v, want nil without a request", err)
+	}
+}
@@ -0,0 +1,96 @@
+package gex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i247app/gex/tracing"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	var childParent string
+
+	app := NewApp(HostConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	app.AddRoute("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, child := tracing.Start(r.Context(), "load user")
+		childParent = tracing.SpanFromContext(r.Context()).SpanContext().SpanID
+		child.End()
+	})
+	app.AddRoute("GET /fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	h := TracingMiddleware(tracing.NewTracer(exporter))(app)
+
+	tests := []struct {
+		name        string
+		target      string
+		traceparent string
+		wantName    string
+		wantStatus  int
+		wantTraceID string
+		wantParent  string
+		wantErr     bool
+		wantSpans   int
+	}{
+		{name: "new trace", target: "/users/7", wantName: "GET /users/{id}", wantStatus: 200, wantSpans: 2},
+		{
+			name: "continues a sampled trace", target: "/users/7", traceparent: testTraceparent,
+			wantName: "GET /users/{id}", wantStatus: 200, wantTraceID: testTraceID, wantParent: testParentID, wantSpans: 2,
+		},
+		{
+			name: "unsampled trace is not exported", target: "/users/7",
+			traceparent: "00-" + testTraceID + "-" + testParentID + "-00",
+		},
+		{name: "unmatched", target: "/nope", wantName: "HTTP GET", wantStatus: 404, wantSpans: 1},
+		{name: "server error", target: "/fail", wantName: "GET /fail", wantStatus: 502, wantErr: true, wantSpans: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.Spans()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("exported %d spans, want %d", len(spans), tt.wantSpans)
+			}
+			if tt.wantSpans == 0 {
+				return
+			}
+
+			server := spans[len(spans)-1]
+			if server.Name != tt.wantName || server.Kind != tracing.SpanKindServer {
+				t.Errorf("server span = %q kind %d, want %q", server.Name, server.Kind, tt.wantName)
+			}
+			if got := server.Attributes["http.response.status_code"]; got != tt.wantStatus {
+				t.Errorf("status attribute = %v, want %d", got, tt.wantStatus)
+			}
+			if tt.wantTraceID != "" && server.SpanContext.TraceID != tt.wantTraceID {
+				t.Errorf("trace ID = %s, want %s", server.SpanContext.TraceID, tt.wantTraceID)
+			}
+			if server.ParentSpanID != tt.wantParent {
+				t.Errorf("parent = %q, want %q", server.ParentSpanID, tt.wantParent)
+			}
+			if (server.Err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", server.Err, tt.wantErr)
+			}
+
+			if tt.wantSpans == 2 {
+				child := spans[0]
+				if child.SpanContext.TraceID != server.SpanContext.TraceID || child.ParentSpanID != server.SpanContext.SpanID {
+					t.Errorf("child %+v is not a child of the server span %+v", child.SpanContext, server.SpanContext)
+				}
+				if childParent != server.SpanContext.SpanID {
+					t.Errorf("handler context span = %s, want the server span", childParent)
+				}
+			}
+		})
+	}
+}
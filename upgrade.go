@@ -0,0 +1,277 @@
+package gex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultUpgradeTimeout bounds how long a binary upgrade waits for the new
+// process to report ready when HostConfig.UpgradeTimeout is zero
+const DefaultUpgradeTimeout = 30 * time.Second
+
+// Environment passed to the upgraded process, see App.Upgrade
+const (
+	upgradeFDsEnv     = "GEX_UPGRADE_FDS"
+	upgradeNamesEnv   = "GEX_UPGRADE_NAMES"
+	upgradeReadyFDEnv = "GEX_UPGRADE_READY_FD"
+)
+
+// Listener names used in the handoff
+const mainListenerName = "main"
+
+// Upgrade re-executes the running binary with the same arguments and hands it
+// the open listeners, so no connection is refused during a deploy. It returns
+// once the new process is serving; the caller then shuts this one down, which
+// drains in-flight requests and runs the shutdown hooks. On failure the new
+// process is killed and this one keeps serving.
+//
+// Start calls Upgrade on SIGUSR2 when HostConfig.GracefulUpgrade is set.
+//
+// Under systemd the new process is reported as the service's main process
+// with sd_notify MAINPID=, so systemd keeps it when this one exits. The unit
+// must accept notifications from the main process:
+//
+//	[Service]
+//	NotifyAccess=main
+//	ExecReload=/bin/kill -USR2 $MAINPID
+func (a *App) Upgrade() error {
+	if !a.upgrading.CompareAndSwap(false, true) {
+		return errors.New("upgrade already in progress")
+	}
+	defer a.upgrading.Store(false)
+
+	a.listenerMu.Lock()
+	listeners := []namedListener{{name: mainListenerName, listener: a.listener}}
+	for _, aux := range a.aux {
+		listeners = append(listeners, namedListener{name: aux.name, listener: aux.listener})
+	}
+	a.listenerMu.Unlock()
+	if listeners[0].listener == nil {
+		return errors.New("server is not listening")
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	names := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		fl, ok := l.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("%s listener %T can't be handed off", l.name, l.listener)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("error duplicating %s listener: %w", l.name, err)
+		}
+		files = append(files, f)
+		names = append(names, l.name)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("error creating readiness pipe: %w", err)
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error locating executable: %w", err)
+	}
+
+	env := append(os.Environ(),
+		upgradeFDsEnv+"="+strconv.Itoa(len(listeners)),
+		upgradeNamesEnv+"="+strings.Join(names, ":"),
+		upgradeReadyFDEnv+"="+strconv.Itoa(inheritedFirstFD+len(listeners)),
+	)
+	proc, err := startProcess(exe, env, files)
+	if err != nil {
+		return fmt.Errorf("error starting new process: %w", err)
+	}
+	fmt.Printf("Upgrade: started new process %d, waiting for it to be ready\n", proc.Pid)
+
+	// Our copy of the write end must be closed so a crashed child reads as EOF
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	readyR.SetReadDeadline(time.Now().Add(limit(a.HostConfig.UpgradeTimeout, DefaultUpgradeTimeout)))
+	if _, err := readyR.Read(make([]byte, 1)); err != nil {
+		proc.Kill()
+		go proc.Wait()
+		return fmt.Errorf("new process did not become ready: %w", err)
+	}
+
+	// Under systemd, the new process becomes the main one before this one exits
+	if err := sdNotify("MAINPID=" + strconv.Itoa(proc.Pid)); err != nil {
+		proc.Kill()
+		go proc.Wait()
+		return fmt.Errorf("error handing the main PID to the new process: %w", err)
+	}
+	go proc.Wait()
+
+	// The new process serves the Unix socket now; don't remove it on close
+	for _, l := range listeners {
+		if ul, ok := l.listener.(interface{ SetUnlinkOnClose(bool) }); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	fmt.Printf("Upgrade: process %d is ready, shutting down\n", proc.Pid)
+	return nil
+}
+
+// startProcess starts exe with this process's arguments, stdio and files as
+// descriptors 3 and up. Unlike os/exec it leaves the descriptors as they are:
+// File.Fd would switch the listeners, whose flags the copies share, to blocking
+// mode, and Accept in this process could then no longer be interrupted.
+func startProcess(exe string, env []string, files []*os.File) (*os.Process, error) {
+	fds := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+	for _, f := range files {
+		rc, err := f.SyscallConn()
+		if err != nil {
+			return nil, err
+		}
+		if err := rc.Control(func(fd uintptr) { fds = append(fds, fd) }); err != nil {
+			return nil, err
+		}
+	}
+
+	argv := append([]string{exe}, os.Args[1:]...)
+	pid, _, err := syscall.StartProcess(exe, argv, &syscall.ProcAttr{Env: env, Files: fds})
+	if err != nil {
+		return nil, err
+	}
+	return os.FindProcess(pid)
+}
+
+// handleUpgrades calls Upgrade on each upgrade signal and cancels the run
+// context once one succeeds
+func (a *App) handleUpgrades(ctx context.Context, cancel context.CancelFunc) {
+	if !a.HostConfig.GracefulUpgrade || upgradeSignal == nil {
+		return
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, upgradeSignal)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			if err := a.Upgrade(); err != nil {
+				fmt.Printf("Upgrade failed: %v\n", err)
+				continue
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Listeners handed over by a parent process start at this file descriptor
+const inheritedFirstFD = 3
+
+var (
+	inheritedOnce      sync.Once
+	inheritedMu        sync.Mutex
+	inheritedListeners []namedListener
+	inheritedReady     *os.File
+	inheritedErr       error
+)
+
+// inheritedListener returns the listener called name handed over by the
+// parent process during an upgrade, if any. Each listener can be taken once.
+func inheritedListener(name string) (net.Listener, bool, error) {
+	inheritedOnce.Do(inheritUpgradeFiles)
+	if inheritedErr != nil {
+		return nil, false, inheritedErr
+	}
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	for i, l := range inheritedListeners {
+		if l.name == name {
+			inheritedListeners = append(inheritedListeners[:i], inheritedListeners[i+1:]...)
+			return l.listener, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// inheritUpgradeFiles takes the listeners and readiness pipe handed over by Upgrade
+func inheritUpgradeFiles() {
+	inheritedListeners, inheritedReady, inheritedErr = upgradeFiles(inheritedFirstFD)
+}
+
+// upgradeFiles reads the upgrade environment, with listeners starting at file
+// descriptor first, then unsets it so a later upgrade starts clean
+func upgradeFiles(first int) ([]namedListener, *os.File, error) {
+	defer func() {
+		os.Unsetenv(upgradeFDsEnv)
+		os.Unsetenv(upgradeNamesEnv)
+		os.Unsetenv(upgradeReadyFDEnv)
+	}()
+
+	count, err := strconv.Atoi(os.Getenv(upgradeFDsEnv))
+	if err != nil || count <= 0 {
+		return nil, nil, nil
+	}
+	var ready *os.File
+	if fd, err := strconv.Atoi(os.Getenv(upgradeReadyFDEnv)); err == nil {
+		ready = os.NewFile(uintptr(fd), "upgrade-ready")
+	}
+	listeners, err := fileListeners(first, count, strings.Split(os.Getenv(upgradeNamesEnv), ":"))
+	return listeners, ready, err
+}
+
+// notifyUpgradeReady tells the parent process, if this one was started by
+// Upgrade, that it is serving and the parent may shut down
+func notifyUpgradeReady() {
+	inheritedOnce.Do(inheritUpgradeFiles)
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	if inheritedReady != nil {
+		inheritedReady.Write([]byte{1})
+		inheritedReady.Close()
+		inheritedReady = nil
+	}
+}
+
+// sdNotify sends state to systemd's notification socket. It does nothing
+// when the process was not started by systemd.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
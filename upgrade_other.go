@@ -0,0 +1,8 @@
+//go:build !unix
+
+package gex
+
+import "os"
+
+// upgradeSignal is nil where listener handoff is not supported
+var upgradeSignal os.Signal
@@ -0,0 +1,168 @@
+//go:build unix
+
+package gex
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// dupFD duplicates the descriptor of f, so the copy can be handed to code that closes it
+func dupFD(t *testing.T, f *os.File) int {
+	t.Helper()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestUpgradeFiles(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lnFile, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lnFile.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readyR.Close()
+	defer readyW.Close()
+
+	t.Setenv(upgradeFDsEnv, "1")
+	t.Setenv(upgradeNamesEnv, mainListenerName)
+	t.Setenv(upgradeReadyFDEnv, strconv.Itoa(dupFD(t, readyW)))
+
+	listeners, ready, err := upgradeFiles(dupFD(t, lnFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].name != mainListenerName {
+		t.Fatalf("listeners = %+v, want one called %s", listeners, mainListenerName)
+	}
+	defer listeners[0].listener.Close()
+	if got := listeners[0].listener.Addr().String(); got != ln.Addr().String() {
+		t.Errorf("inherited listener on %s, want %s", got, ln.Addr())
+	}
+	for _, env := range []string{upgradeFDsEnv, upgradeNamesEnv, upgradeReadyFDEnv} {
+		if _, ok := os.LookupEnv(env); ok {
+			t.Errorf("%s still set", env)
+		}
+	}
+
+	// notifyUpgradeReady writes one byte to the pipe, once
+	inheritedOnce.Do(func() {})
+	inheritedMu.Lock()
+	inheritedReady = ready
+	inheritedMu.Unlock()
+	notifyUpgradeReady()
+	notifyUpgradeReady()
+	readyW.Close()
+
+	readyR.SetReadDeadline(time.Now().Add(time.Second))
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(readyR); err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != 1 {
+		t.Errorf("parent read %d bytes, want 1", got.Len())
+	}
+}
+
+func TestUpgradeFilesWithoutUpgrade(t *testing.T) {
+	listeners, ready, err := upgradeFiles(inheritedFirstFD)
+	if listeners != nil || ready != nil || err != nil {
+		t.Errorf("upgradeFiles = %v, %v, %v, want nothing", listeners, ready, err)
+	}
+}
+
+// TestUpgradeHelperProcess is the new process started by App.Upgrade in TestUpgrade
+func TestUpgradeHelperProcess(t *testing.T) {
+	switch os.Getenv("GEX_TEST_UPGRADE_HELPER") {
+	case "":
+		t.Skip("started by TestUpgrade")
+	case "ready":
+		ln, ok, err := inheritedListener(mainListenerName)
+		if err != nil || !ok {
+			os.Exit(2)
+		}
+		ln.Close()
+		notifyUpgradeReady()
+	case "hang":
+		time.Sleep(10 * time.Second)
+	}
+	os.Exit(0)
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		helper  string
+		wantErr bool
+	}{
+		{"ready", false},
+		{"exit", true},
+		{"hang", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.helper, func(t *testing.T) {
+			args := os.Args
+			os.Args = []string{args[0], "-test.run=^TestUpgradeHelperProcess$"}
+			defer func() { os.Args = args }()
+			t.Setenv("GEX_TEST_UPGRADE_HELPER", tt.helper)
+
+			notify, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify"), Net: "unixgram"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer notify.Close()
+			t.Setenv("NOTIFY_SOCKET", notify.LocalAddr().String())
+
+			app := NewApp(HostConfig{ServerHost: "127.0.0.1", ServerPort: "0", UpgradeTimeout: 500 * time.Millisecond}, nil)
+			startApp(t, app)
+
+			start := time.Now()
+			err = app.Upgrade()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upgrade = %v, want error %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Upgrade took %v, want it bounded by UpgradeTimeout", elapsed)
+			}
+
+			// Handing the listener off must leave it non-blocking here, or
+			// Accept can't be interrupted and shutting down hangs
+			rc, err := app.listener.(syscall.Conn).SyscallConn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flags uintptr
+			rc.Control(func(fd uintptr) {
+				flags, _, _ = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+			})
+			if flags&syscall.O_NONBLOCK == 0 {
+				t.Error("listener is in blocking mode after Upgrade")
+			}
+
+			// Only a successful upgrade hands systemd the new main PID
+			notify.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			buf := make([]byte, 64)
+			n, _ := notify.Read(buf)
+			if got := string(buf[:n]); tt.wantErr != (got == "") || (!tt.wantErr && !bytes.HasPrefix(buf[:n], []byte("MAINPID="))) {
+				t.Errorf("sd_notify message = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package gex
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal triggers App.Upgrade when HostConfig.GracefulUpgrade is set
+var upgradeSignal os.Signal = syscall.SIGUSR2
@@ -0,0 +1,259 @@
+package gex
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Validate checks the `validate` struct tags of v, a struct or pointer to one.
+// Rules are comma separated:
+//
+//	required    not the zero value
+//	min=N       minimum length for strings, slices and maps, minimum value for numbers
+//	max=N       maximum, as for min
+//	oneof=a b   one of the space separated values
+//	email       a valid email address
+//
+// Nested structs are validated too. Failures are returned as ErrBadRequest
+// with a []FieldError as details. A malformed or unknown rule is a programming
+// error, returned as is; Handle reports it when the handler is built.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	if err := checkValidateTags(rv.Type()); err != nil {
+		return err
+	}
+
+	var errs []FieldError
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return ErrBadRequest.WithDetails(errs)
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fv := v.Field(i)
+
+		if field.IsExported() {
+			for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+				if rule = strings.TrimSpace(rule); rule == "" {
+					continue
+				}
+				if msg := checkRule(fv, rule); msg != "" {
+					ruleName, _, _ := strings.Cut(rule, "=")
+					*errs = append(*errs, FieldError{Field: name, Rule: ruleName, Message: msg})
+				}
+			}
+		}
+
+		// Descend into nested structs. Fields of embedded structs are named
+		// as if they were declared in the outer struct, as they are bound.
+		inner := fv
+		if inner.Kind() == reflect.Pointer && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct && inner.Type().PkgPath() != "time" {
+			if promoted(field) {
+				validateStruct(inner, prefix, errs)
+			} else {
+				validateStruct(inner, name+".", errs)
+			}
+		}
+	}
+}
+
+// promoted reports whether the fields of field, an embedded struct, are
+// promoted to the outer struct rather than named under it
+func promoted(field reflect.StructField) bool {
+	return field.Anonymous && field.Tag.Get("json") == ""
+}
+
+// checkRule returns a message describing why v breaks rule, or "" if it doesn't
+func checkRule(v reflect.Value, rule string) string {
+	ruleName, arg, _ := strings.Cut(rule, "=")
+
+	if ruleName == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+
+	// Other rules only apply to values that are set
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch ruleName {
+	case "min", "max":
+		// checkValidateTags made sure the limit parses
+		limit, _ := strconv.ParseFloat(arg, 64)
+		size, isLen := measure(v)
+		if ruleName == "min" && size < limit {
+			if isLen {
+				return fmt.Sprintf("length must be at least %s", arg)
+			}
+			return fmt.Sprintf("must be at least %s", arg)
+		}
+		if ruleName == "max" && size > limit {
+			if isLen {
+				return fmt.Sprintf("length must be at most %s", arg)
+			}
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+	case "oneof":
+		if v.IsZero() {
+			return ""
+		}
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(arg) {
+			if value == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", arg)
+	case "email":
+		if v.Kind() != reflect.String || v.String() == "" {
+			return ""
+		}
+		if addr, err := mail.ParseAddress(v.String()); err != nil || addr.Address != v.String() {
+			return "must be a valid email address"
+		}
+	}
+	return ""
+}
+
+// checkedTags caches the result of checkValidateTags per struct type
+var checkedTags sync.Map // reflect.Type -> error
+
+// checkValidateTags reports the first malformed or unknown rule in the
+// `validate` tags of t, a struct type, or of the structs nested in it
+func checkValidateTags(t reflect.Type) error {
+	if v, ok := checkedTags.Load(t); ok {
+		err, _ := v.(error)
+		return err
+	}
+	err := checkStructTags(t, map[reflect.Type]bool{})
+	checkedTags.Store(t, err)
+	return err
+}
+
+func checkStructTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		if field.IsExported() {
+			for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+				if rule = strings.TrimSpace(rule); rule == "" {
+					continue
+				}
+				if !validRule(rule, field.Type) {
+					return fmt.Errorf("gex: invalid validate rule %q on %s.%s of type %s", rule, t, field.Name, field.Type)
+				}
+			}
+		}
+
+		inner := field.Type
+		if inner.Kind() == reflect.Pointer {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct && inner.PkgPath() != "time" {
+			if err := checkStructTags(inner, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validRule reports whether rule is well formed and applies to fields of type t
+func validRule(rule string, t reflect.Type) bool {
+	ruleName, arg, hasArg := strings.Cut(rule, "=")
+	switch ruleName {
+	case "required", "email":
+		return !hasArg
+	case "min", "max":
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		_, err := strconv.ParseFloat(arg, 64)
+		return err == nil && measurable(t)
+	case "oneof":
+		return strings.TrimSpace(arg) != ""
+	}
+	return false
+}
+
+// measurable reports whether measure handles values of type t
+func measurable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// measure returns the length of strings, slices and maps, or the value of numbers
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	}
+	return 0, false
+}
+
+// fieldName is the name a client uses for a field: its JSON, query or path name, else the Go name
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	for _, tag := range []string{"query", "path"} {
+		if name := field.Tag.Get(tag); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}